@@ -2,4 +2,7 @@ module github.com/plantimals/go-opml
 
 go 1.16
 
-require github.com/gilliek/go-opml v1.0.0
+require (
+	github.com/gilliek/go-opml v1.0.0
+	golang.org/x/text v0.3.6
+)
@@ -0,0 +1,207 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"encoding/xml"
+)
+
+// xmlNamespace is the namespace bound to the reserved "xml" prefix.
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Element is an XML element unknown to this package. Its content is kept
+// verbatim so that it can be written back unchanged.
+type Element struct {
+	XMLName xml.Name   `json:"name"`
+	Attrs   []xml.Attr `xml:",any,attr" json:"attrs,omitempty"`
+	Content string     `xml:",innerxml" json:"content,omitempty"`
+}
+
+// outlineAttrs lists the outline attributes mapped to fields of Outline, in
+// the order they are written.
+var outlineAttrs = []struct {
+	name  string
+	field func(o *Outline) *string
+}{
+	{"text", func(o *Outline) *string { return &o.Text }},
+	{"type", func(o *Outline) *string { return &o.Type }},
+	{"isComment", func(o *Outline) *string { return &o.IsComment }},
+	{"isBreakpoint", func(o *Outline) *string { return &o.IsBreakpoint }},
+	{"created", func(o *Outline) *string { return &o.Created }},
+	{"category", func(o *Outline) *string { return &o.Category }},
+	{"xmlUrl", func(o *Outline) *string { return &o.XMLURL }},
+	{"htmlUrl", func(o *Outline) *string { return &o.HTMLURL }},
+	{"url", func(o *Outline) *string { return &o.URL }},
+	{"language", func(o *Outline) *string { return &o.Language }},
+	{"title", func(o *Outline) *string { return &o.Title }},
+	{"version", func(o *Outline) *string { return &o.Version }},
+	{"description", func(o *Outline) *string { return &o.Description }},
+}
+
+// headElements lists the head elements mapped to fields of Head, in the
+// order they are written.
+var headElements = []struct {
+	name  string
+	field func(h *Head) *string
+}{
+	{"title", func(h *Head) *string { return &h.Title }},
+	{"dateCreated", func(h *Head) *string { return &h.DateCreated }},
+	{"dateModified", func(h *Head) *string { return &h.DateModified }},
+	{"ownerName", func(h *Head) *string { return &h.OwnerName }},
+	{"ownerEmail", func(h *Head) *string { return &h.OwnerEmail }},
+	{"ownerId", func(h *Head) *string { return &h.OwnerID }},
+	{"docs", func(h *Head) *string { return &h.Docs }},
+	{"expansionState", func(h *Head) *string { return &h.ExpansionState }},
+	{"vertScrollState", func(h *Head) *string { return &h.VertScrollState }},
+	{"windowTop", func(h *Head) *string { return &h.WindowTop }},
+	{"windowBottom", func(h *Head) *string { return &h.WindowBottom }},
+	{"windowLeft", func(h *Head) *string { return &h.WindowLeft }},
+	{"windowRight", func(h *Head) *string { return &h.WindowRight }},
+}
+
+// field returns a pointer to the field of h holding the named element, or
+// nil if the element is not mapped to a field.
+func (h *Head) field(name xml.Name) *string {
+	for _, e := range headElements {
+		if e.name == name.Local {
+			return e.field(h)
+		}
+	}
+	return nil
+}
+
+// knownAttr returns a pointer to the field of o holding the named attribute,
+// or nil if the attribute is not mapped to a field.
+func (o *Outline) knownAttr(name string) *string {
+	for _, a := range outlineAttrs {
+		if a.name == name {
+			return a.field(o)
+		}
+	}
+	return nil
+}
+
+// Attr returns the value of the named attribute, whether it is mapped to a
+// field of o or kept in o.Attrs. Prefixed attributes are named as written in
+// the document, e.g. "podcast:guid". Attr returns the empty string if the
+// attribute is not set.
+func (o *Outline) Attr(name string) string {
+	if f := o.knownAttr(name); f != nil {
+		return *f
+	}
+	for _, a := range o.Attrs {
+		if attrName(a.Name) == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// SetAttr sets the value of the named attribute. Setting an attribute that is
+// not mapped to a field of o to the empty string removes it from o.Attrs.
+func (o *Outline) SetAttr(name, value string) {
+	if f := o.knownAttr(name); f != nil {
+		*f = value
+		return
+	}
+	for i, a := range o.Attrs {
+		if attrName(a.Name) != name {
+			continue
+		}
+		if value == "" {
+			o.Attrs = append(o.Attrs[:i], o.Attrs[i+1:]...)
+		} else {
+			o.Attrs[i].Value = value
+		}
+		return
+	}
+	if value != "" {
+		o.Attrs = append(o.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+}
+
+// attrName returns the name of an attribute as written in a document.
+func attrName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// The encoding/xml package replaces namespace prefixes by namespace URLs when
+// decoding and does not map them back to prefixes when encoding. Names of
+// unknown attributes and elements are thus "unresolved" once decoded: they
+// are turned back into names holding the prefix used in the document, which
+// the encoder writes unchanged.
+
+// declarations appends to decls the namespace declarations found in attrs.
+func declarations(decls []xml.Attr, attrs []xml.Attr) []xml.Attr {
+	decls = decls[:len(decls):len(decls)]
+	for _, a := range attrs {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			decls = append(decls, a)
+		}
+	}
+	return decls
+}
+
+// unresolveName returns the name as written in the document, given the
+// namespace declarations in scope.
+func unresolveName(n xml.Name, decls []xml.Attr) xml.Name {
+	switch n.Space {
+	case "":
+		return n
+	case "xmlns":
+		return xml.Name{Local: "xmlns:" + n.Local}
+	case xmlNamespace:
+		return xml.Name{Local: "xml:" + n.Local}
+	}
+
+	for i := len(decls) - 1; i >= 0; i-- {
+		if decls[i].Value != n.Space {
+			continue
+		}
+		if decls[i].Name.Space == "" {
+			// Default namespace.
+			return xml.Name{Local: n.Local}
+		}
+		return xml.Name{Local: decls[i].Name.Local + ":" + n.Local}
+	}
+
+	// Undeclared prefixes are left as is by the decoder.
+	return xml.Name{Local: n.Space + ":" + n.Local}
+}
+
+func unresolveAttrs(attrs []xml.Attr, decls []xml.Attr) []xml.Attr {
+	for i := range attrs {
+		attrs[i].Name = unresolveName(attrs[i].Name, decls)
+	}
+	return attrs
+}
+
+func unresolveElements(elements []Element, decls []xml.Attr) {
+	for i := range elements {
+		e := &elements[i]
+		d := declarations(decls, e.Attrs)
+		e.XMLName = unresolveName(e.XMLName, d)
+		e.Attrs = unresolveAttrs(e.Attrs, d)
+	}
+}
+
+func (h *Head) unresolve(decls []xml.Attr) {
+	decls = declarations(decls, h.Attrs)
+	h.Attrs = unresolveAttrs(h.Attrs, decls)
+	unresolveElements(h.Elements, decls)
+}
+
+func unresolveOutlines(outlines []Outline, decls []xml.Attr) {
+	for i := range outlines {
+		o := &outlines[i]
+		d := declarations(decls, o.Attrs)
+		o.Attrs = unresolveAttrs(o.Attrs, d)
+		unresolveElements(o.Elements, d)
+		unresolveOutlines(o.Outlines, d)
+	}
+}
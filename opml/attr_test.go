@@ -0,0 +1,101 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"strings"
+	"testing"
+)
+
+const extendedOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+	<head>
+		<title>Podcasts</title>
+		<podcast:owner email="foo@bar.com">Kevin <b>G</b></podcast:owner>
+		<generator>Feeds &amp; Co</generator>
+	</head>
+	<body>
+		<outline text="Go Time" type="rss" xmlUrl="https://changelog.com/gotime/feed" isPodcast="true" podcast:guid="42" xml:lang="en">
+			<outline text="Episode" iconUrl="http://example.com/icon.png"></outline>
+			<note>Some <em>rich</em> note</note>
+		</outline>
+	</body>
+</opml>`
+
+func TestRoundTripUnknown(t *testing.T) {
+	doc, err := NewOPML([]byte(extendedOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opml, err := doc.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opml != extendedOPML {
+		t.Errorf("Invalid generated OPML: expected\n\n%s\n\nfound\n\n%s",
+			extendedOPML, opml)
+	}
+}
+
+func TestOutlineAttr(t *testing.T) {
+	doc, err := NewOPML([]byte(extendedOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := &doc.Body.Outlines[0]
+	if v := o.Attr("isPodcast"); v != "true" {
+		t.Errorf("Wrong isPodcast attribute: expected 'true', found '%s'", v)
+	}
+	if v := o.Attr("podcast:guid"); v != "42" {
+		t.Errorf("Wrong podcast:guid attribute: expected '42', found '%s'", v)
+	}
+	if v := o.Attr("xmlUrl"); v != "https://changelog.com/gotime/feed" {
+		t.Errorf("Wrong xmlUrl attribute: found '%s'", v)
+	}
+
+	o.SetAttr("title", "Go Time!")
+	if o.Title != "Go Time!" {
+		t.Errorf("Wrong title: expected 'Go Time!', found '%s'", o.Title)
+	}
+
+	o.SetAttr("isPodcast", "")
+	o.SetAttr("updateInterval", "60")
+	if len(o.Attrs) != 3 || o.Attr("isPodcast") != "" || o.Attr("updateInterval") != "60" {
+		t.Errorf("Wrong unknown attributes: %v", o.Attrs)
+	}
+
+	if len(o.Elements) != 1 || o.Elements[0].XMLName.Local != "note" {
+		t.Fatalf("Wrong unknown elements: %v", o.Elements)
+	}
+	if o.Elements[0].Content != "Some <em>rich</em> note" {
+		t.Errorf("Wrong element content: found '%s'", o.Elements[0].Content)
+	}
+}
+
+func TestDecoderUnknown(t *testing.T) {
+	d := NewDecoder(strings.NewReader(extendedOPML))
+
+	head, err := d.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(head.Elements) != 2 || head.Elements[0].XMLName.Local != "podcast:owner" {
+		t.Errorf("Wrong head elements: %v", head.Elements)
+	}
+
+	n, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := n.Outline.Attr("podcast:guid"); v != "42" {
+		t.Errorf("Wrong podcast:guid attribute: expected '42', found '%s'", v)
+	}
+	if v := n.Outline.Attr("xml:lang"); v != "en" {
+		t.Errorf("Wrong xml:lang attribute: expected 'en', found '%s'", v)
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"html"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotBookmarks is returned when parsing a document that is not a Netscape
+// bookmark file.
+var ErrNotBookmarks = errors.New("opml: not a Netscape bookmark file")
+
+// ParseBookmarks parses a bookmark file in the Netscape format, as exported
+// by browsers. Folders become outlines with children, and bookmarks become
+// outlines of type "link", with the url attribute set from HREF, the created
+// attribute from ADD_DATE and the category attribute from the TAGS, as tags.
+// Descriptions are kept in the description attribute.
+func ParseBookmarks(r io.Reader) (*OPML, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := bookmarkParser{s: string(b), doc: &OPML{Version: "2.0"}}
+	p.parse()
+	if !p.found {
+		return nil, ErrNotBookmarks
+	}
+	return p.doc, nil
+}
+
+// bookmarkParser parses a bookmark file. The format is HTML, not XML: end
+// tags are often missing, so the parser only relies on the DL, DT, H3, A and
+// DD tags.
+type bookmarkParser struct {
+	s     string
+	pos   int
+	doc   *OPML
+	found bool // whether a DL element was found
+
+	lists  []*[]Outline // lists of outlines of the open DL elements
+	folder *Outline     // folder whose H3 element was read, waiting for its DL
+	last   func() *Outline
+
+	text    *strings.Builder // text being read, if any
+	setText func(s string)
+}
+
+func (p *bookmarkParser) parse() {
+	for p.pos < len(p.s) {
+		if p.s[p.pos] != '<' {
+			end := strings.IndexByte(p.s[p.pos:], '<')
+			if end < 0 {
+				end = len(p.s) - p.pos
+			}
+			if p.text != nil {
+				p.text.WriteString(p.s[p.pos : p.pos+end])
+			}
+			p.pos += end
+			continue
+		}
+
+		name, attrs, end := p.tag()
+		switch name {
+		case "dl":
+			p.flush()
+			p.openList()
+		case "/dl":
+			p.flush()
+			if len(p.lists) > 0 {
+				p.lists = p.lists[:len(p.lists)-1]
+			}
+			p.last = nil
+		case "dt":
+			p.flush()
+		case "h3":
+			p.flush()
+			p.folder = nil
+			o := p.add(Outline{Created: bookmarkDate(attrs["add_date"])})
+			p.read(func(s string) {
+				o().Text = s
+				p.folder = o()
+			})
+		case "a":
+			p.flush()
+			p.add(Outline{
+				Type:     "link",
+				URL:      attrs["href"],
+				Created:  bookmarkDate(attrs["add_date"]),
+				Category: bookmarkTags(attrs["tags"]),
+			})
+			o := p.last
+			p.read(func(s string) { o().Text = s })
+		case "/h3", "/a":
+			p.flush()
+		case "dd":
+			p.flush()
+			if o := p.last; o != nil {
+				p.read(func(s string) { o().Description = s })
+			}
+		case "title":
+			p.flush()
+			p.read(func(s string) { p.doc.Head.Title = s })
+		case "/title":
+			p.flush()
+		}
+		p.pos = end
+	}
+	p.flush()
+}
+
+// tag reads the tag at the current position, and returns its lower case
+// name, prefixed with a slash for end tags, its attributes and the position
+// following it. Comments and declarations have no name.
+func (p *bookmarkParser) tag() (string, map[string]string, int) {
+	s := p.s[p.pos:]
+	if strings.HasPrefix(s, "<!--") {
+		end := strings.Index(s, "-->")
+		if end < 0 {
+			return "", nil, len(p.s)
+		}
+		return "", nil, p.pos + end + len("-->")
+	}
+
+	i := 1
+	for i < len(s) && s[i] != '>' && s[i] != ' ' && s[i] != '\t' && s[i] != '\r' && s[i] != '\n' {
+		i++
+	}
+	name := strings.ToLower(s[1:i])
+	if strings.HasPrefix(name, "!") || strings.HasPrefix(name, "?") {
+		name = ""
+	}
+
+	attrs := make(map[string]string)
+	for i < len(s) && s[i] != '>' {
+		if c := s[i]; c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '/' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && !strings.ContainsRune(" \t\r\n=>", rune(s[i])) {
+			i++
+		}
+		key := strings.ToLower(s[start:i])
+		for i < len(s) && strings.ContainsRune(" \t\r\n", rune(s[i])) {
+			i++
+		}
+		if i == len(s) || s[i] != '=' {
+			attrs[key] = ""
+			continue
+		}
+		i++
+		for i < len(s) && strings.ContainsRune(" \t\r\n", rune(s[i])) {
+			i++
+		}
+		var value string
+		if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			value = s[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\r\n>", rune(s[i])) {
+				i++
+			}
+			value = s[start:i]
+		}
+		attrs[key] = html.UnescapeString(value)
+	}
+	if i < len(s) {
+		i++ // >
+	}
+	return name, attrs, p.pos + i
+}
+
+// openList starts a DL element, holding the children of the last folder, or
+// the top-level outlines.
+func (p *bookmarkParser) openList() {
+	p.found = true
+	switch {
+	case p.folder != nil:
+		p.lists = append(p.lists, &p.folder.Outlines)
+	case len(p.lists) == 0:
+		p.lists = append(p.lists, &p.doc.Body.Outlines)
+	default:
+		// A list without folder: its outlines are added to the enclosing
+		// list.
+		p.lists = append(p.lists, p.lists[len(p.lists)-1])
+	}
+	p.folder = nil
+	p.last = nil
+}
+
+// add appends an outline to the current list and returns a function
+// returning it, valid until the list is modified.
+func (p *bookmarkParser) add(o Outline) func() *Outline {
+	if len(p.lists) == 0 {
+		// Bookmarks outside of any list.
+		p.lists = append(p.lists, &p.doc.Body.Outlines)
+	}
+	list := p.lists[len(p.lists)-1]
+	*list = append(*list, o)
+	i := len(*list) - 1
+	p.last = func() *Outline { return &(*list)[i] }
+	return p.last
+}
+
+// read starts reading text, passed to set once complete.
+func (p *bookmarkParser) read(set func(s string)) {
+	p.text = new(strings.Builder)
+	p.setText = set
+}
+
+// flush completes the text being read, if any.
+func (p *bookmarkParser) flush() {
+	if p.text == nil {
+		return
+	}
+	p.setText(strings.TrimSpace(html.UnescapeString(p.text.String())))
+	p.text = nil
+	p.setText = nil
+}
+
+// bookmarkDate converts an ADD_DATE attribute, in seconds since the Unix
+// epoch, to a created attribute.
+func bookmarkDate(s string) string {
+	sec, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || sec <= 0 {
+		return ""
+	}
+	return FormatDate(time.Unix(sec, 0))
+}
+
+// bookmarkTags converts a comma-separated TAGS attribute to a category
+// attribute.
+func bookmarkTags(s string) string {
+	var categories []Category
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			categories = append(categories, Category{Path: []string{tag}, Tag: true})
+		}
+	}
+	return FormatCategories(categories)
+}
+
+// WriteBookmarks writes doc as a bookmark file in the Netscape format.
+// Outlines with children become folders, and the other ones bookmarks,
+// pointing to their url, htmlUrl or xmlUrl attribute. Outlines without URL
+// become empty folders, and the URL of outlines with children becomes a
+// bookmark with their text, first in their folder. The created attribute is
+// written as ADD_DATE, the tags of the category attribute as TAGS and the
+// description as DD.
+func WriteBookmarks(w io.Writer, doc *OPML) error {
+	title := doc.Head.Title
+	if title == "" {
+		title = "Bookmarks"
+	}
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	b.WriteString("<!-- This is an automatically generated file.\n")
+	b.WriteString("     It will be read and overwritten.\n")
+	b.WriteString("     DO NOT EDIT! -->\n")
+	b.WriteString(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n")
+	b.WriteString("<TITLE>" + html.EscapeString(title) + "</TITLE>\n")
+	b.WriteString("<H1>" + html.EscapeString(title) + "</H1>\n")
+	writeBookmarkList(&b, doc.Body.Outlines, 0)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeBookmarkList(b *strings.Builder, outlines []Outline, depth int) {
+	indent := strings.Repeat("    ", depth)
+	b.WriteString(indent + "<DL><p>\n")
+	for i := range outlines {
+		o := &outlines[i]
+		b.WriteString(indent + "    <DT>")
+
+		href := outlineHref(o)
+		if len(o.Outlines) > 0 || href == "" {
+			b.WriteString("<H3" + bookmarkAttrs(o, "") + ">" + html.EscapeString(o.Text) + "</H3>\n")
+			writeBookmarkDescription(b, o, indent)
+			children := o.Outlines
+			if href != "" {
+				link := Outline{Text: o.Text, URL: href, Created: o.Created, Category: o.Category}
+				children = append([]Outline{link}, children...)
+			}
+			writeBookmarkList(b, children, depth+1)
+			continue
+		}
+		b.WriteString("<A" + bookmarkAttrs(o, href) + ">" + html.EscapeString(o.Text) + "</A>\n")
+		writeBookmarkDescription(b, o, indent)
+	}
+	b.WriteString(indent + "</DL><p>\n")
+}
+
+// bookmarkAttrs returns the attributes of the A or H3 element of the outline.
+func bookmarkAttrs(o *Outline, href string) string {
+	var attrs string
+	if href != "" {
+		attrs += ` HREF="` + html.EscapeString(href) + `"`
+	}
+	if t, err := ParseDate(o.Created); err == nil && o.Created != "" {
+		attrs += ` ADD_DATE="` + strconv.FormatInt(t.Unix(), 10) + `"`
+	}
+	var tags []string
+	for _, c := range o.Categories() {
+		if c.Tag {
+			tags = append(tags, c.String())
+		}
+	}
+	if href != "" && len(tags) > 0 {
+		attrs += ` TAGS="` + html.EscapeString(strings.Join(tags, ",")) + `"`
+	}
+	return attrs
+}
+
+func writeBookmarkDescription(b *strings.Builder, o *Outline, indent string) {
+	if o.Description != "" {
+		b.WriteString(indent + "    <DD>" + html.EscapeString(o.Description) + "\n")
+	}
+}
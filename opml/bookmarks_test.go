@@ -0,0 +1,152 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const bookmarksTestFile = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>My Bookmarks</TITLE>
+<H1>My Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1609459200" LAST_MODIFIED="1609459300" PERSONAL_TOOLBAR_FOLDER="true">Tech &amp; Code</H3>
+    <DD>Programming
+    <DL><p>
+        <DT><A HREF="https://go.dev/?a=1&amp;b=2" ADD_DATE="1609459200" ICON="data:image/png;base64,AA==" TAGS="go, golang">The Go <b>Programming</b> Language</A>
+        <DD>Official site
+        <DT><H3>Empty</H3>
+        <DL><p>
+        </DL><p>
+        <dt><a href='https://www.rust-lang.org/' add_date=1609459300>Rust</a>
+    </DL><p>
+    <DT><A HREF="https://news.ycombinator.com/">Hacker News</A>
+</DL><p>
+`
+
+func TestParseBookmarks(t *testing.T) {
+	doc, err := ParseBookmarks(strings.NewReader(bookmarksTestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Head.Title != "My Bookmarks" || doc.Version != "2.0" {
+		t.Errorf("Wrong document: %+v", doc)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "Tech & Code(The Go Programming Language Empty Rust) Hacker News" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+
+	folder := doc.Body.Outlines[0]
+	if folder.Created != "Fri, 01 Jan 2021 00:00:00 GMT" || folder.Description != "Programming" || folder.Type != "" {
+		t.Errorf("Wrong folder: %+v", folder)
+	}
+	expected := Outline{
+		Text:        "The Go Programming Language",
+		Type:        "link",
+		URL:         "https://go.dev/?a=1&b=2",
+		Created:     "Fri, 01 Jan 2021 00:00:00 GMT",
+		Category:    "go,golang",
+		Description: "Official site",
+	}
+	if o := folder.Outlines[0]; !reflect.DeepEqual(o, expected) {
+		t.Errorf("Wrong bookmark: expected %+v, found %+v", expected, o)
+	}
+	if o := folder.Outlines[2]; o.URL != "https://www.rust-lang.org/" || o.Created != "Fri, 01 Jan 2021 00:01:40 GMT" || o.Description != "" {
+		t.Errorf("Wrong bookmark: %+v", o)
+	}
+
+	if _, err := ParseBookmarks(strings.NewReader("<html><body>Hello</body></html>")); err != ErrNotBookmarks {
+		t.Errorf("Expected ErrNotBookmarks, found %v", err)
+	}
+}
+
+func TestWriteBookmarks(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		folder("Tech",
+			Outline{Text: "Go <blog>", Type: "rss", XMLURL: "https://go.dev/feed", HTMLURL: "https://go.dev/blog",
+				Created: "Fri, 01 Jan 2021 00:00:00 GMT", Category: "/Tech,go,golang"},
+			Outline{Text: "Notes", Description: "To sort"}),
+		{Text: "Docs", Type: "link", URL: "https://go.dev/doc?a=1&b=2", Description: "Docs & specs"},
+	}}}
+
+	var b strings.Builder
+	if err := WriteBookmarks(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3>Tech</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/blog" ADD_DATE="1609459200" TAGS="go,golang">Go &lt;blog&gt;</A>
+        <DT><H3>Notes</H3>
+        <DD>To sort
+        <DL><p>
+        </DL><p>
+    </DL><p>
+    <DT><A HREF="https://go.dev/doc?a=1&amp;b=2">Docs</A>
+    <DD>Docs &amp; specs
+</DL><p>
+`
+	if b.String() != expected {
+		t.Errorf("Wrong bookmarks: expected\n%s\nfound\n%s", expected, b.String())
+	}
+
+	parsed, err := ParseBookmarks(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree := texts(parsed.Body.Outlines); tree != "Tech(Go <blog> Notes) Docs" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if o := parsed.Body.Outlines[1]; o.URL != doc.Body.Outlines[1].URL || o.Description != "Docs & specs" {
+		t.Errorf("Wrong bookmark: %+v", o)
+	}
+}
+
+func TestWriteBookmarksFolderURL(t *testing.T) {
+	show := Outline{Text: "Show", Type: "rss", XMLURL: "https://example.com/show.xml", Category: "podcast",
+		Outlines: []Outline{{Text: "Episode 1", Type: "link", URL: "https://example.com/1"}}}
+	doc := &OPML{Body: Body{Outlines: []Outline{show}}}
+
+	var b strings.Builder
+	if err := WriteBookmarks(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<DL><p>
+    <DT><H3>Show</H3>
+    <DL><p>
+        <DT><A HREF="https://example.com/show.xml" TAGS="podcast">Show</A>
+        <DT><A HREF="https://example.com/1">Episode 1</A>
+    </DL><p>
+</DL><p>
+`
+	if !strings.HasSuffix(b.String(), expected) {
+		t.Errorf("Wrong bookmarks: expected\n%s\nfound\n%s", expected, b.String())
+	}
+
+	parsed, err := ParseBookmarks(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree := texts(parsed.Body.Outlines); tree != "Show(Show Episode 1)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if o := parsed.Body.Outlines[0].Outlines[0]; o.URL != show.XMLURL {
+		t.Errorf("Wrong bookmark: %+v", o)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheEntry is a document stored in a Cache, along with the validators sent
+// by the server to revalidate it.
+type CacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Charset      string `json:"charset,omitempty"` // from the Content-Type header
+	Body         []byte `json:"body"`
+}
+
+// Cache stores the documents retrieved by a Fetcher, by URL. Its methods may
+// be called concurrently.
+type Cache interface {
+	// Get returns the entry stored for the URL, or nil if there is none.
+	Get(url string) (*CacheEntry, error)
+
+	// Set stores the entry for the URL.
+	Set(url string, e *CacheEntry) error
+}
+
+// MemoryCache is a Cache keeping entries in memory. The zero value is an
+// empty cache ready to use.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]*CacheEntry
+}
+
+// Get implements the Cache interface.
+func (c *MemoryCache) Get(url string) (*CacheEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[url], nil
+}
+
+// Set implements the Cache interface.
+func (c *MemoryCache) Set(url string, e *CacheEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*CacheEntry)
+	}
+	c.entries[url] = e
+	return nil
+}
+
+// DiskCache is a Cache keeping entries as JSON files in a directory, which is
+// created if needed.
+type DiskCache struct {
+	Dir string
+}
+
+// Get implements the Cache interface.
+func (c DiskCache) Get(url string) (*CacheEntry, error) {
+	b, err := ioutil.ReadFile(c.path(url))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var e CacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Set implements the Cache interface. The entry is written to a temporary
+// file first, so that concurrent readers never see a partial entry.
+func (c DiskCache) Set(url string, e *CacheEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(c.Dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path(url))
+}
+
+// path returns the path of the file holding the entry of the URL.
+func (c DiskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,155 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// conditionalHandler serves the test document with the given validators,
+// answering 304 to matching conditional requests. It counts the full
+// responses sent.
+func conditionalHandler(t *testing.T, etag, lastModified string, sent *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if etag != "" && r.Header.Get("If-None-Match") == etag ||
+			lastModified != "" && r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		if lastModified != "" {
+			w.Header().Set("Last-Modified", lastModified)
+		}
+		*sent++
+		feedsHandler(t)(w, r)
+	}
+}
+
+func testConditionalGet(t *testing.T, cache Cache, etag, lastModified string) {
+	sent := 0
+	server := httptest.NewServer(conditionalHandler(t, etag, lastModified, &sent))
+	defer server.Close()
+
+	f := &Fetcher{Cache: cache}
+	for i := 0; i < 3; i++ {
+		res, err := f.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.NotModified != (i > 0) {
+			t.Errorf("Wrong NotModified flag for request %d: %v", i, res.NotModified)
+		}
+		if res.ETag != etag || res.LastModified != lastModified {
+			t.Errorf("Wrong validators for request %d: %+v", i, res)
+		}
+		testDoc(t, res.OPML)
+	}
+
+	if sent != 1 {
+		t.Errorf("Document sent %d times, expected once", sent)
+	}
+}
+
+func TestFetcherCache(t *testing.T) {
+	testConditionalGet(t, &MemoryCache{}, `"v1"`, "")
+	testConditionalGet(t, &MemoryCache{}, "", "Sun, 06 Jul 2008 21:02:00 GMT")
+	testConditionalGet(t, DiskCache{Dir: t.TempDir()}, `"v1"`, "Sun, 06 Jul 2008 21:02:00 GMT")
+}
+
+func TestFetcherWithoutValidators(t *testing.T) {
+	sent := 0
+	server := httptest.NewServer(conditionalHandler(t, "", "", &sent))
+	defer server.Close()
+
+	cache := &MemoryCache{}
+	f := &Fetcher{Cache: cache}
+	for i := 0; i < 2; i++ {
+		res, err := f.Get(context.Background(), server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.NotModified {
+			t.Errorf("Unexpected NotModified flag for request %d", i)
+		}
+	}
+	if e, _ := cache.Get(server.URL); e != nil {
+		t.Errorf("Unexpected cache entry: %+v", e)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	cache := DiskCache{Dir: t.TempDir() + "/cache"}
+
+	e, err := cache.Get("http://example.com/feeds.opml")
+	if err != nil || e != nil {
+		t.Fatalf("Expected no entry, found %v, %v", e, err)
+	}
+
+	expected := &CacheEntry{ETag: `"v1"`, Body: []byte("<opml/>")}
+	if err := cache.Set("http://example.com/feeds.opml", expected); err != nil {
+		t.Fatal(err)
+	}
+	e, err = cache.Get("http://example.com/feeds.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e, expected) {
+		t.Errorf("Wrong cache entry: expected %+v, found %+v", expected, e)
+	}
+}
+
+func TestFetcherContentEncoding(t *testing.T) {
+	b, err := ioutil.ReadFile("../testdata/feeds.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoders := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser {
+			return zlib.NewWriter(w)
+		},
+		"raw deflate": func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		},
+	}
+
+	for name, encoder := range encoders {
+		var buf bytes.Buffer
+		w := encoder(&buf)
+		w.Write(b)
+		w.Close()
+
+		encoding := name
+		if name == "raw deflate" {
+			encoding = "deflate"
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", encoding)
+			w.Write(buf.Bytes())
+		}))
+
+		doc, err := new(Fetcher).Fetch(context.Background(), server.URL)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		testDoc(t, doc)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Clone returns a deep copy of doc.
+func (doc *OPML) Clone() *OPML {
+	c := *doc
+	c.Attrs = cloneAttrs(doc.Attrs)
+	c.Head.Attrs = cloneAttrs(doc.Head.Attrs)
+	c.Head.Elements = cloneElements(doc.Head.Elements)
+	c.Body.Outlines = cloneOutlines(doc.Body.Outlines)
+	return &c
+}
+
+func cloneOutlines(outlines []Outline) []Outline {
+	if outlines == nil {
+		return nil
+	}
+	c := make([]Outline, len(outlines))
+	for i, o := range outlines {
+		c[i] = o
+		c[i].Attrs = cloneAttrs(o.Attrs)
+		c[i].Elements = cloneElements(o.Elements)
+		c[i].Outlines = cloneOutlines(o.Outlines)
+	}
+	return c
+}
+
+func cloneAttrs(attrs []xml.Attr) []xml.Attr {
+	if attrs == nil {
+		return nil
+	}
+	return append([]xml.Attr(nil), attrs...)
+}
+
+func cloneElements(elements []Element) []Element {
+	if elements == nil {
+		return nil
+	}
+	c := make([]Element, len(elements))
+	for i, e := range elements {
+		c[i] = e
+		c[i].Attrs = cloneAttrs(e.Attrs)
+	}
+	return c
+}
+
+// Canonical returns a copy of doc in canonical form, so that documents that
+// only differ by their formatting have the same canonical form:
+//
+//   - whitespace is trimmed from values and runs of whitespace are collapsed
+//     into a single space;
+//   - unknown attributes are sorted by name and the empty ones are removed;
+//   - dates are written in the format of FormatDate, in UTC;
+//   - numbers and expansion states are written without padding;
+//   - isComment and isBreakpoint are written in lower case, and removed when
+//     false, their default value;
+//   - the content of unknown elements is written with normalized escaping;
+//   - the document is encoded in UTF-8.
+//
+// Values that cannot be parsed are only normalized for whitespace.
+func (doc *OPML) Canonical() *OPML {
+	c := doc.Clone()
+	c.Encoding = ""
+	c.Version = normalizeSpace(c.Version)
+	c.Attrs = canonicalAttrs(c.Attrs)
+	c.Head.canonicalize()
+	canonicalOutlines(c.Body.Outlines)
+	return c
+}
+
+// CanonicalXML exports the canonical form of the document to a XML string.
+// See Canonical.
+func (doc *OPML) CanonicalXML() (string, error) {
+	return doc.Canonical().XML()
+}
+
+// Digest returns the hexadecimal SHA-256 hash of the canonical form of the
+// document. Documents with the same canonical form have the same digest.
+func (doc *OPML) Digest() (string, error) {
+	s, err := doc.CanonicalXML()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (h *Head) canonicalize() {
+	for _, e := range headElements {
+		f := e.field(h)
+		*f = normalizeSpace(*f)
+	}
+	h.DateCreated = canonicalDate(h.DateCreated)
+	h.DateModified = canonicalDate(h.DateModified)
+	if lines, err := h.Expansion(); err == nil {
+		h.SetExpansion(lines)
+	}
+	if n, err := h.VertScroll(); err == nil {
+		h.SetVertScroll(n)
+	}
+	if w, err := h.Window(); err == nil {
+		h.SetWindow(w)
+	}
+	h.Attrs = canonicalAttrs(h.Attrs)
+	canonicalElements(h.Elements)
+}
+
+func canonicalOutlines(outlines []Outline) {
+	for i := range outlines {
+		o := &outlines[i]
+		for _, a := range outlineAttrs {
+			f := a.field(o)
+			*f = normalizeSpace(*f)
+		}
+		o.Created = canonicalDate(o.Created)
+		o.IsComment = canonicalBool(o.IsComment)
+		o.IsBreakpoint = canonicalBool(o.IsBreakpoint)
+		o.Attrs = canonicalAttrs(o.Attrs)
+		canonicalElements(o.Elements)
+		canonicalOutlines(o.Outlines)
+	}
+}
+
+// canonicalAttrs normalizes and sorts unknown attributes. Empty attributes
+// are removed, except namespace declarations.
+func canonicalAttrs(attrs []xml.Attr) []xml.Attr {
+	var c []xml.Attr
+	for _, a := range attrs {
+		a.Value = normalizeSpace(a.Value)
+		name := attrName(a.Name)
+		if a.Value != "" || name == "xmlns" || strings.HasPrefix(name, "xmlns:") {
+			c = append(c, a)
+		}
+	}
+	sort.SliceStable(c, func(i, j int) bool {
+		return attrName(c[i].Name) < attrName(c[j].Name)
+	})
+	return c
+}
+
+func canonicalElements(elements []Element) {
+	for i := range elements {
+		e := &elements[i]
+		e.Attrs = canonicalAttrs(e.Attrs)
+		e.Content = canonicalContent(e.Content)
+	}
+}
+
+// canonicalContent re-encodes the content of an unknown element, so that
+// references and attributes are written the same way. Runs of whitespace are
+// collapsed, but not trimmed, since the content may be mixed. The content is
+// returned trimmed but otherwise unchanged if it is not well-formed.
+func canonicalContent(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.ContainsAny(content, "<&") {
+		return normalizeSpace(content)
+	}
+
+	var buf bytes.Buffer
+	d := xml.NewDecoder(strings.NewReader(content))
+	e := xml.NewEncoder(&buf)
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return content
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			t.Name = rawName(t.Name)
+			for i := range t.Attr {
+				t.Attr[i].Name = rawName(t.Attr[i].Name)
+			}
+			t.Attr = canonicalAttrs(t.Attr)
+			tok = t
+		case xml.EndElement:
+			t.Name = rawName(t.Name)
+			tok = t
+		case xml.CharData:
+			tok = xml.CharData(collapseSpace(string(t)))
+		}
+		if err := e.EncodeToken(xml.CopyToken(tok)); err != nil {
+			return content
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return content
+	}
+	return buf.String()
+}
+
+func canonicalDate(s string) string {
+	if s == "" {
+		return s
+	}
+	if t, err := ParseDate(s); err == nil {
+		return FormatDate(t)
+	}
+	return s
+}
+
+func canonicalBool(s string) string {
+	switch strings.ToLower(s) {
+	case "true":
+		return "true"
+	case "false":
+		return ""
+	}
+	return s
+}
+
+// normalizeSpace trims s and collapses its runs of whitespace.
+func normalizeSpace(s string) string {
+	return strings.TrimSpace(collapseSpace(s))
+}
+
+// collapseSpace replaces the runs of XML whitespace of s by a single space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
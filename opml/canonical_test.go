@@ -0,0 +1,103 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"testing"
+)
+
+const noisyOPML = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<opml version=" 2.0 " xmlns:p="urn:p">
+  <head>
+    <title>
+      Caf&#233;
+      Feeds
+    </title>
+    <dateCreated>2014-01-02T03:04:05+01:00</dateCreated>
+    <expansionState>1,3</expansionState>
+    <windowTop>010</windowTop>
+    <p:meta b="2"   a='1'>  x &#38;   <i>y</i>  </p:meta>
+  </head>
+  <body>
+    <outline xmlUrl="http://example.com/rss" text="Go" p:z="" p:b="2" p:a="1" isComment="FALSE" isBreakpoint="True"/>
+  </body>
+</opml>`
+
+const cleanOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0" xmlns:p="urn:p">
+	<head>
+		<title>Café Feeds</title>
+		<dateCreated>Thu, 02 Jan 2014 02:04:05 GMT</dateCreated>
+		<expansionState>1, 3</expansionState>
+		<windowTop>10</windowTop>
+		<p:meta a="1" b="2">x &amp; <i>y</i></p:meta>
+	</head>
+	<body>
+		<outline text="Go" isBreakpoint="true" xmlUrl="http://example.com/rss" p:a="1" p:b="2"></outline>
+	</body>
+</opml>`
+
+func TestCanonical(t *testing.T) {
+	noisy, err := NewOPML([]byte(noisyOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, err := NewOPML([]byte(cleanOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, doc := range []*OPML{noisy, clean} {
+		output, err := doc.CanonicalXML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output != cleanOPML {
+			t.Errorf("Invalid canonical OPML: expected\n\n%s\n\nfound\n\n%s", cleanOPML, output)
+		}
+	}
+
+	d1, err := noisy.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := clean.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d1 != d2 || len(d1) != 64 {
+		t.Errorf("Wrong digests: %s and %s", d1, d2)
+	}
+
+	clean.Body.Outlines[0].Text = "Golang"
+	if d3, _ := clean.Digest(); d3 == d1 {
+		t.Error("Expected different digests for different documents")
+	}
+
+	// The original document is left unchanged.
+	if noisy.Version != " 2.0 " || noisy.Encoding != "ISO-8859-1" || len(noisy.Body.Outlines[0].Attrs) != 3 {
+		t.Errorf("Document modified by Canonical: %+v", noisy)
+	}
+}
+
+func TestClone(t *testing.T) {
+	doc, err := NewOPML([]byte(extendedOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := doc.Clone()
+	c.Body.Outlines[0].Outlines[0].Text = "Changed"
+	c.Body.Outlines[0].Attrs[0].Value = "false"
+	c.Head.Elements[0].Attrs[0].Value = "changed"
+
+	opml, err := doc.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opml != extendedOPML {
+		t.Errorf("Document modified through its clone:\n\n%s", opml)
+	}
+}
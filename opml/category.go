@@ -0,0 +1,208 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"sort"
+	"strings"
+)
+
+// Category is an element of the category attribute of an outline. As
+// defined by OPML 2.0, the attribute is a comma-separated list of
+// slash-delimited category paths, e.g. "/Tech/Go", or of tags without slash,
+// e.g. "golang".
+type Category struct {
+	Path []string // elements of the path, or the tag
+	Tag  bool
+}
+
+// String returns the category as written in the category attribute.
+func (c Category) String() string {
+	if c.Tag {
+		return strings.Join(c.Path, "")
+	}
+	return "/" + strings.Join(c.Path, "/")
+}
+
+// ParseCategories parses a category attribute. Empty categories are
+// ignored.
+func ParseCategories(s string) []Category {
+	var categories []Category
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		if !strings.Contains(c, "/") {
+			if c != "" {
+				categories = append(categories, Category{Path: []string{c}, Tag: true})
+			}
+			continue
+		}
+		var path []string
+		for _, name := range strings.Split(c, "/") {
+			if name = strings.TrimSpace(name); name != "" {
+				path = append(path, name)
+			}
+		}
+		if len(path) > 0 {
+			categories = append(categories, Category{Path: path})
+		}
+	}
+	return categories
+}
+
+// FormatCategories returns the category attribute listing the categories.
+func FormatCategories(categories []Category) string {
+	s := make([]string, len(categories))
+	for i, c := range categories {
+		s[i] = c.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// Categories returns the parsed category attribute of the outline.
+func (o *Outline) Categories() []Category {
+	return ParseCategories(o.Category)
+}
+
+// SetCategories sets the category attribute of the outline.
+func (o *Outline) SetCategories(categories []Category) {
+	o.Category = FormatCategories(categories)
+}
+
+// categoryFolder returns the first category path of a category attribute.
+func categoryFolder(category string) []string {
+	for _, c := range ParseCategories(category) {
+		if !c.Tag {
+			return c.Path
+		}
+	}
+	return nil
+}
+
+// GroupByCategory moves the outlines without children, e.g. feeds, to the
+// folders given by the first path of their category attribute, as last
+// children. Missing folders are created at the end of their parent. The
+// outlines without category path and the folders stay in place, except the
+// folders emptied, which are removed.
+func (doc *OPML) GroupByCategory() {
+	for {
+		path, folder := doc.misplacedOutline()
+		if path == nil {
+			return
+		}
+		if _, err := doc.Reparent(path, doc.makeFolder(folder)); err != nil {
+			return
+		}
+
+		// Appending folders and removing the outline leave the path of
+		// its former parent unchanged.
+		for parent := path[:len(path)-1]; len(parent) > 0; parent = parent[:len(parent)-1] {
+			o, _ := doc.Outline(parent)
+			if len(o.Outlines) > 0 || o.XMLURL != "" {
+				break
+			}
+			doc.Remove(parent)
+		}
+	}
+}
+
+// misplacedOutline returns the path of the first outline without children
+// that is not in the folder given by its category attribute, along with the
+// folder, or nil if there is none.
+func (doc *OPML) misplacedOutline() ([]int, []string) {
+	var found, texts []string
+	var foundPath []int
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		texts = append(texts[:depth], o.Text)
+		if foundPath != nil || len(o.Outlines) > 0 {
+			return nil
+		}
+		folder := categoryFolder(o.Category)
+		if folder != nil && strings.Join(folder, "\x00") != strings.Join(texts[:depth], "\x00") {
+			found, foundPath = folder, copyPath(path)
+		}
+		return nil
+	})
+	return foundPath, found
+}
+
+// makeFolder returns the path of the folder given by the texts of the
+// folders leading to it, appending the missing ones.
+func (doc *OPML) makeFolder(texts []string) []int {
+	var path []int
+	for _, text := range texts {
+		list, _ := doc.children(path)
+		i := 0
+		for i < len(*list) && ((*list)[i].Text != text || len((*list)[i].Outlines) == 0) {
+			i++
+		}
+		if i == len(*list) {
+			doc.Insert(append(copyPath(path), i), Outline{Text: text})
+		}
+		path = append(path, i)
+	}
+	return path
+}
+
+// DeriveCategories sets the category attributes of the outlines without
+// children from their folders: the path of the folders is added first to the
+// categories of each outline nested in folders, unless present.
+func (doc *OPML) DeriveCategories() {
+	var folder []string
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		folder = folder[:depth]
+		if len(o.Outlines) > 0 {
+			folder = append(folder, o.Text)
+			return nil
+		}
+		if depth == 0 {
+			return nil
+		}
+		c := Category{Path: append([]string(nil), folder...)}
+		categories := []Category{c}
+		for _, other := range o.Categories() {
+			if other.String() != c.String() {
+				categories = append(categories, other)
+			}
+		}
+		o.SetCategories(categories)
+		return nil
+	})
+}
+
+// CategoryCount is a category in use in a document.
+type CategoryCount struct {
+	Category Category
+	Count    int // number of outlines in the category
+}
+
+// CategoryCounts returns the categories of the outlines of doc, sorted by
+// their String form.
+func (doc *OPML) CategoryCounts() []CategoryCount {
+	counts := make(map[string]*CategoryCount)
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		seen := make(map[string]bool)
+		for _, c := range o.Categories() {
+			s := c.String()
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			if counts[s] == nil {
+				counts[s] = &CategoryCount{Category: c}
+			}
+			counts[s].Count++
+		}
+		return nil
+	})
+
+	list := make([]CategoryCount, 0, len(counts))
+	for _, c := range counts {
+		list = append(list, *c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Category.String() < list[j].Category.String()
+	})
+	return list
+}
@@ -0,0 +1,118 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCategories(t *testing.T) {
+	tests := []struct {
+		attr       string
+		categories []Category
+		formatted  string
+	}{
+		{"", nil, ""},
+		{"/Boston/Weather", []Category{{Path: []string{"Boston", "Weather"}}}, "/Boston/Weather"},
+		{
+			" /Harvard/Berkman/ , golang,, /, Tech/Go ",
+			[]Category{
+				{Path: []string{"Harvard", "Berkman"}},
+				{Path: []string{"golang"}, Tag: true},
+				{Path: []string{"Tech", "Go"}},
+			},
+			"/Harvard/Berkman,golang,/Tech/Go",
+		},
+	}
+
+	for _, test := range tests {
+		categories := ParseCategories(test.attr)
+		if !reflect.DeepEqual(categories, test.categories) {
+			t.Errorf("%q: expected %+v, found %+v", test.attr, test.categories, categories)
+		}
+		if s := FormatCategories(categories); s != test.formatted {
+			t.Errorf("%q: expected %q, found %q", test.attr, test.formatted, s)
+		}
+	}
+
+	o := Outline{}
+	o.SetCategories([]Category{{Path: []string{"news"}, Tag: true}, {Path: []string{"A", "B"}}})
+	if o.Category != "news,/A/B" || len(o.Categories()) != 2 {
+		t.Errorf("Wrong category: %q", o.Category)
+	}
+}
+
+func TestGroupByCategory(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		folder("Tech",
+			Outline{Text: "Go", Category: "golang,/Lang/Go"},
+			Outline{Text: "Rust", Category: "/Lang"}),
+		folder("News",
+			Outline{Text: "HN"},
+			Outline{Text: "LWN", Category: "linux"}),
+		{Text: "Zig", Category: "/Lang"},
+	}}}
+
+	doc.Body.Outlines[1].Title = "Daily news"
+	doc.Body.Outlines[1].Outlines[0].Outlines = []Outline{{Text: "Front page"}}
+	doc.Head.ExpansionState = "2, 3"
+
+	doc.GroupByCategory()
+	if tree := texts(doc.Body.Outlines); tree != "News(HN(Front page) LWN) Lang(Go(Go) Rust Zig)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if news := doc.Body.Outlines[0]; news.Title != "Daily news" {
+		t.Errorf("Folder attributes not kept: %+v", news)
+	}
+	if doc.Head.ExpansionState != "1, 2" {
+		t.Errorf("Wrong expansion state: found '%s'", doc.Head.ExpansionState)
+	}
+}
+
+func TestDeriveCategories(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		folder("Tech",
+			Outline{Text: "Go", Category: "golang,/Tech"},
+			folder("Lang", Outline{Text: "Rust", Category: "/Old"})),
+		{Text: "HN", Category: "news"},
+	}}}
+
+	doc.DeriveCategories()
+	var found []string
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		found = append(found, o.Category)
+		return nil
+	})
+	expected := []string{"", "/Tech,golang", "", "/Tech/Lang,/Old", "news"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Wrong categories: expected %q, found %q", expected, found)
+	}
+
+	// Derived categories are used to rebuild the folders.
+	tree := texts(doc.Body.Outlines)
+	doc.GroupByCategory()
+	if found := texts(doc.Body.Outlines); found != tree {
+		t.Errorf("Wrong tree: expected '%s', found '%s'", tree, found)
+	}
+}
+
+func TestCategoryCounts(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		folder("Tech",
+			Outline{Text: "Go", Category: "/Tech,go,/Tech/"},
+			Outline{Text: "Rust", Category: "/Tech"}),
+		{Text: "HN", Category: "go"},
+	}}}
+
+	counts := doc.CategoryCounts()
+	expected := []CategoryCount{
+		{Category{Path: []string{"Tech"}}, 2},
+		{Category{Path: []string{"go"}, Tag: true}, 2},
+	}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Wrong counts: expected %+v, found %+v", expected, counts)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// CharsetReader returns a reader converting input from the named character
+// encoding to UTF-8. It knows the encodings registered by IANA as well as
+// the labels used by web browsers, e.g. "ISO-8859-1", "windows-1252" or
+// "Shift_JIS". It can be used as the CharsetReader of an xml.Decoder.
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+func lookupEncoding(charset string) (encoding.Encoding, error) {
+	enc, err := ianaindex.IANA.Encoding(charset)
+	if err != nil || enc == nil {
+		enc, err = htmlindex.Get(charset)
+	}
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("opml: unsupported charset %q", charset)
+	}
+	return enc, nil
+}
+
+func isUTF8(charset string) bool {
+	return charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "utf8")
+}
+
+// newXMLDecoder returns a decoder reading r, which is converted to UTF-8
+// according to, by order of precedence: its byte order mark, the given
+// charset, typically coming from an HTTP Content-Type header, or the
+// encoding declared in its XML declaration. The decoder records the encoding
+// of the input in *charset. The returned lineReader counts the lines of the
+// converted input, as read by the decoder.
+func newXMLDecoder(r io.Reader, charset *string) (*xml.Decoder, *lineReader) {
+	br := bufio.NewReader(r)
+	bom, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(bom, []byte{0xef, 0xbb, 0xbf}):
+		br.Discard(3)
+		*charset = "UTF-8"
+	case bytes.HasPrefix(bom, []byte{0xfe, 0xff}), bytes.HasPrefix(bom, []byte{0xff, 0xfe}):
+		*charset = "UTF-16"
+	}
+
+	lines := &lineReader{r: br}
+	external := *charset != ""
+	if external && !isUTF8(*charset) {
+		if enc, err := lookupEncoding(*charset); err == nil {
+			if *charset == "UTF-16" {
+				enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+			}
+			lines.r = bufio.NewReader(transform.NewReader(br, enc.NewDecoder()))
+		} else {
+			// Let the declared encoding apply.
+			external = false
+			*charset = ""
+		}
+	}
+
+	// Since lines is an io.ByteReader, the decoder reads from it directly,
+	// and its input is lines itself. Conversion is done below it, so that
+	// lines are counted on the converted input.
+	d := xml.NewDecoder(lines)
+	d.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if external {
+			// The input has already been converted to UTF-8.
+			return input, nil
+		}
+		*charset = label
+		r, err := CharsetReader(label, lines.r)
+		if err != nil {
+			return nil, err
+		}
+		lines.r = bufio.NewReader(r)
+		return lines, nil
+	}
+	return d, lines
+}
+
+// decode parses a document from r. See newXMLDecoder for the meaning of
+// charset.
+func decode(r io.Reader, charset string) (*OPML, error) {
+	var root OPML
+	d, _ := newXMLDecoder(r, &charset)
+	if err := d.Decode(&root); err != nil {
+		return nil, err
+	}
+
+	if !isUTF8(charset) {
+		root.Encoding = charset
+	}
+	return &root, nil
+}
+
+// xmlHeader returns the XML declaration for the given charset.
+func xmlHeader(charset string) string {
+	if isUTF8(charset) {
+		return xml.Header
+	}
+	return fmt.Sprintf("<?xml version=\"1.0\" encoding=\"%s\"?>\n", charset)
+}
+
+// encodeCharset returns a writer converting UTF-8 to the given charset and
+// writing to w. Characters that cannot be represented in the charset are
+// written as character references. The writer must be closed to flush it.
+func encodeCharset(w io.Writer, charset string) (io.WriteCloser, error) {
+	if isUTF8(charset) {
+		return nopCloser{w}, nil
+	}
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(charset, "UTF-16") {
+		enc = unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	}
+	return transform.NewWriter(w, encoding.HTMLEscapeUnsupported(enc.NewEncoder())), nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const charsetOPML = `<?xml version="1.0" encoding="%s"?>
+<opml version="2.0">
+	<head>
+		<title>%s</title>
+	</head>
+	<body>
+		<outline text="%s"></outline>
+	</body>
+</opml>`
+
+func charsetDoc(charset, title, text string) []byte {
+	return []byte(strings.Replace(strings.Replace(strings.Replace(charsetOPML,
+		"%s", charset, 1), "%s", title, 1), "%s", text, 1))
+}
+
+func TestCharsetRoundTrip(t *testing.T) {
+	sjis, _ := japanese.ShiftJIS.NewEncoder().String("日本語")
+
+	tests := []struct {
+		charset string
+		input   []byte
+		text    string
+	}{
+		{"ISO-8859-1", charsetDoc("ISO-8859-1", "Caf\xe9", "R\xe9sum\xe9"), "Résumé"},
+		{"windows-1252", charsetDoc("windows-1252", "Caf\xe9", "\x80 5"), "€ 5"},
+		{"Shift_JIS", charsetDoc("Shift_JIS", "Caf&#233;", sjis), "日本語"},
+	}
+
+	for _, test := range tests {
+		doc, err := NewOPML(test.input)
+		if err != nil {
+			t.Errorf("%s: %v", test.charset, err)
+			continue
+		}
+		if doc.Head.Title != "Café" {
+			t.Errorf("%s: wrong title: expected 'Café', found '%s'", test.charset, doc.Head.Title)
+		}
+		if text := doc.Body.Outlines[0].Text; text != test.text {
+			t.Errorf("%s: wrong text: expected '%s', found '%s'", test.charset, test.text, text)
+		}
+		if doc.Encoding != test.charset {
+			t.Errorf("%s: wrong encoding: found '%s'", test.charset, doc.Encoding)
+		}
+
+		// Round trip in the original charset.
+		output, err := doc.XML()
+		if err != nil {
+			t.Errorf("%s: %v", test.charset, err)
+			continue
+		}
+		expected := strings.Replace(string(test.input), "Caf&#233;", "Café", 1)
+		if test.charset == "Shift_JIS" {
+			// é cannot be represented in Shift_JIS.
+			expected = string(test.input)
+		}
+		if output != expected {
+			t.Errorf("%s: invalid generated OPML: expected\n\n%q\n\nfound\n\n%q",
+				test.charset, expected, output)
+		}
+	}
+}
+
+func TestCharsetOutput(t *testing.T) {
+	doc := OPML{
+		Version:  "2.0",
+		Head:     Head{Title: "Café €"},
+		Encoding: "ISO-8859-1",
+	}
+
+	output, err := doc.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(output, `<?xml version="1.0" encoding="ISO-8859-1"?>`) {
+		t.Errorf("Wrong XML declaration: %s", output)
+	}
+	if !strings.Contains(output, "<title>Caf\xe9 &#8364;</title>") {
+		t.Errorf("Wrong encoded title: %q", output)
+	}
+
+	doc.Encoding = "klingon"
+	if _, err := doc.XML(); err == nil {
+		t.Error("Expected failure!")
+	}
+}
+
+func TestBOM(t *testing.T) {
+	utf8BOM := append([]byte("\xef\xbb\xbf"), charsetDoc("UTF-8", "Café", "x")...)
+	doc, err := NewOPML(utf8BOM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Head.Title != "Café" || doc.Encoding != "" {
+		t.Errorf("Wrong document: title '%s', encoding '%s'", doc.Head.Title, doc.Encoding)
+	}
+
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes(
+		charsetDoc("UTF-16", "Café", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = NewOPMLFromReader(bytes.NewReader(utf16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Head.Title != "Café" || doc.Encoding != "UTF-16" {
+		t.Errorf("Wrong document: title '%s', encoding '%s'", doc.Head.Title, doc.Encoding)
+	}
+
+	d := NewDecoder(bytes.NewReader(utf16))
+	n, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Outline.Text != "x" {
+		t.Errorf("Wrong outline text: expected 'x', found '%s'", n.Outline.Text)
+	}
+}
+
+func TestFetchCharset(t *testing.T) {
+	// The document does not declare its encoding: the HTTP header applies.
+	body := strings.Replace(string(charsetDoc("", "Caf\xe9", "x")), ` encoding=""`, "", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml; charset=ISO-8859-1")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	doc, err := new(Fetcher).Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Head.Title != "Café" || doc.Encoding != "ISO-8859-1" {
+		t.Errorf("Wrong document: title '%s', encoding '%s'", doc.Head.Title, doc.Encoding)
+	}
+}
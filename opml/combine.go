@@ -0,0 +1,221 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"strings"
+)
+
+// Policy selects, among the occurrences of a duplicate feed, the one whose
+// folder or title is kept.
+type Policy int
+
+// Policies for duplicate feeds.
+const (
+	PolicyFirst   Policy = iota // first occurrence, in document order
+	PolicyLast                  // last occurrence, in document order
+	PolicyDeepest               // most nested occurrence, the first one on ties
+)
+
+// A Combiner combines several documents into one, collapsing duplicate
+// feeds. The zero value uses DefaultNormalizer and keeps the folder and title
+// of the first occurrence of each feed.
+type Combiner struct {
+	// Normalizer normalizes the xmlUrl attributes used to detect duplicate
+	// feeds. If nil, DefaultNormalizer is used.
+	Normalizer *Normalizer
+
+	// Folder selects the occurrence that keeps its position.
+	Folder Policy
+
+	// Title selects the occurrence whose attributes are kept. The
+	// attributes it lacks are taken from the other occurrences.
+	Title Policy
+}
+
+// Occurrence is an occurrence of a feed in the combined documents.
+type Occurrence struct {
+	Doc     int   // index of the document
+	Path    []int // index path of the outline in the document
+	Outline Outline
+}
+
+// Duplicate describes a feed found several times in the combined documents,
+// and collapsed into a single outline.
+type Duplicate struct {
+	// URL is the normalized xmlUrl of the feed.
+	URL string
+
+	// Path is the index path of the collapsed outline in the combined
+	// document.
+	Path []int
+
+	// Occurrences lists the outlines collapsed, in document order. Their
+	// Outlines field is always empty.
+	Occurrences []Occurrence
+}
+
+// Combine combines the documents into one, and reports the duplicate feeds
+// collapsed. The head and version are those of the first document.
+//
+// Feeds, i.e. outlines with an xmlUrl attribute, are matched by normalized
+// xmlUrl and kept once, with their children. Folders are matched by the
+// texts of their ancestors and their own text, and merged. Other outlines
+// are matched by key, as defined by Compare, and kept once. Folders emptied
+// by the removal of duplicates are removed.
+func (c *Combiner) Combine(docs ...*OPML) (*OPML, []Duplicate) {
+	n := c.Normalizer
+	if n == nil {
+		n = &DefaultNormalizer
+	}
+
+	// Collect the occurrences of the feeds.
+	var urls []string
+	feeds := make(map[string][]*feedOccurrence)
+	for i, doc := range docs {
+		doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+			if o.XMLURL == "" {
+				return nil
+			}
+			u := n.Normalize(o.XMLURL)
+			if feeds[u] == nil {
+				urls = append(urls, u)
+			}
+			feeds[u] = append(feeds[u], &feedOccurrence{doc: i, path: copyPath(path), depth: depth, outline: o})
+			return SkipChildren
+		})
+	}
+
+	// Place the outlines, creating their folders as needed.
+	combined := &OPML{Version: "2.0"}
+	if len(docs) > 0 {
+		combined.Version = docs[0].Version
+		combined.Head = docs[0].Clone().Head
+		combined.Attrs = cloneAttrs(docs[0].Attrs)
+		combined.Encoding = docs[0].Encoding
+	}
+	b := combineBuilder{doc: combined, folders: make(map[string][]int), keys: make(map[string]bool)}
+	placed := make(map[string][]int)
+	for i, doc := range docs {
+		var ancestors []*Outline
+		doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+			ancestors = ancestors[:depth]
+			if o.XMLURL != "" {
+				u := n.Normalize(o.XMLURL)
+				occurrences := feeds[u]
+				if w := c.Folder.choose(occurrences); w.doc == i && equalPaths(w.path, path) {
+					placed[u] = b.place(ancestors, c.collapse(occurrences))
+				}
+				return SkipChildren
+			}
+			if len(o.Outlines) > 0 {
+				ancestors = append(ancestors, o)
+				return nil
+			}
+			texts := make([]string, 0, depth+1)
+			for _, a := range ancestors {
+				texts = append(texts, a.Text)
+			}
+			if key := outlineKey(o, append(texts, o.Text)); !b.keys[key] {
+				b.keys[key] = true
+				b.place(ancestors, cloneOutlines([]Outline{*o})[0])
+			}
+			return nil
+		})
+	}
+	combined.Head.ExpansionState = ""
+
+	var duplicates []Duplicate
+	for _, u := range urls {
+		occurrences := feeds[u]
+		if len(occurrences) < 2 {
+			continue
+		}
+		d := Duplicate{URL: u, Path: placed[u]}
+		for _, f := range occurrences {
+			d.Occurrences = append(d.Occurrences, Occurrence{Doc: f.doc, Path: f.path, Outline: f.attrs()})
+		}
+		duplicates = append(duplicates, d)
+	}
+	return combined, duplicates
+}
+
+type feedOccurrence struct {
+	doc     int
+	path    []int
+	depth   int
+	outline *Outline
+}
+
+// attrs returns a copy of the outline without its children.
+func (f *feedOccurrence) attrs() Outline {
+	o := *f.outline
+	o.Outlines = nil
+	return o
+}
+
+// choose returns the occurrence selected by the policy.
+func (p Policy) choose(occurrences []*feedOccurrence) *feedOccurrence {
+	w := occurrences[0]
+	for _, f := range occurrences[1:] {
+		switch p {
+		case PolicyLast:
+			w = f
+		case PolicyDeepest:
+			if f.depth > w.depth {
+				w = f
+			}
+		}
+	}
+	return w
+}
+
+// collapse returns the outline replacing the occurrences of a feed.
+func (c *Combiner) collapse(occurrences []*feedOccurrence) Outline {
+	w := c.Title.choose(occurrences)
+	o := cloneOutlines([]Outline{*w.outline})[0]
+	for _, f := range occurrences {
+		for _, name := range attrNames(f.outline) {
+			if o.Attr(name) == "" {
+				o.SetAttr(name, f.outline.Attr(name))
+			}
+		}
+	}
+	return o
+}
+
+// combineBuilder builds a combined document.
+type combineBuilder struct {
+	doc     *OPML
+	folders map[string][]int // paths of the folders, by ancestor texts
+	keys    map[string]bool  // keys of the outlines other than feeds and folders
+}
+
+// place appends o to the folder given by its ancestors and returns its path.
+func (b *combineBuilder) place(ancestors []*Outline, o Outline) []int {
+	var parent []int
+	var texts []string
+	for _, a := range ancestors {
+		texts = append(texts, a.Text)
+		key := strings.Join(texts, "\x00")
+		path, ok := b.folders[key]
+		if !ok {
+			folder := *a
+			folder.Outlines = nil
+			folder.Attrs = cloneAttrs(folder.Attrs)
+			folder.Elements = cloneElements(folder.Elements)
+			path = b.append(parent, folder)
+			b.folders[key] = path
+		}
+		parent = path
+	}
+	return b.append(parent, o)
+}
+
+func (b *combineBuilder) append(parent []int, o Outline) []int {
+	list, _ := b.doc.children(parent)
+	*list = append(*list, o)
+	return append(copyPath(parent), len(*list)-1)
+}
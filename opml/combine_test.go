@@ -0,0 +1,90 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func combineTestDocs() []*OPML {
+	return []*OPML{
+		{
+			Version: "1.0",
+			Head:    Head{Title: "Reader A", ExpansionState: "1"},
+			Body: Body{Outlines: []Outline{
+				folder("Tech",
+					Outline{Text: "Go", XMLURL: "http://go.dev/feed/"},
+					Outline{Text: "Rust", XMLURL: "https://blog.rust-lang.org/feed.xml"}),
+				Outline{Text: "Docs", URL: "https://go.dev/doc"},
+			}},
+		},
+		{
+			Version: "2.0",
+			Head:    Head{Title: "Reader B"},
+			Body: Body{Outlines: []Outline{
+				folder("Misc",
+					Outline{Text: "The Go Blog", XMLURL: "https://GO.dev/feed", HTMLURL: "https://go.dev/blog"}),
+				folder("Tech",
+					folder("Systems",
+						Outline{Text: "Rust blog", XMLURL: "http://blog.rust-lang.org/feed.xml"}),
+					Outline{Text: "HN", XMLURL: "http://feedproxy.google.com/hn?format=xml"}),
+				Outline{Text: "Docs", URL: "https://go.dev/doc"},
+				Outline{Text: "HN", XMLURL: "https://feeds.feedburner.com/hn"},
+			}},
+		},
+	}
+}
+
+func TestCombine(t *testing.T) {
+	docs := combineTestDocs()
+	combined, duplicates := new(Combiner).Combine(docs...)
+
+	if tree := texts(combined.Body.Outlines); tree != "Tech(Go Rust HN) Docs" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if combined.Head.Title != "Reader A" || combined.Version != "1.0" || combined.Head.ExpansionState != "" {
+		t.Errorf("Wrong head: %+v", combined.Head)
+	}
+
+	// Missing attributes are taken from the other occurrences.
+	if o := combined.Body.Outlines[0].Outlines[0]; o.XMLURL != "http://go.dev/feed/" || o.HTMLURL != "https://go.dev/blog" {
+		t.Errorf("Wrong collapsed outline: %+v", o)
+	}
+
+	var urls []string
+	for _, d := range duplicates {
+		urls = append(urls, d.URL)
+	}
+	expected := []string{"https://go.dev/feed", "https://blog.rust-lang.org/feed.xml", "https://feeds.feedburner.com/hn"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Wrong duplicates: expected %v, found %v", expected, urls)
+	}
+	d := duplicates[0]
+	if !reflect.DeepEqual(d.Path, []int{0, 0}) || len(d.Occurrences) != 2 ||
+		d.Occurrences[1].Doc != 1 || !reflect.DeepEqual(d.Occurrences[1].Path, []int{0, 0}) ||
+		d.Occurrences[1].Outline.Text != "The Go Blog" {
+		t.Errorf("Wrong duplicate: %+v", d)
+	}
+}
+
+func TestCombinePolicies(t *testing.T) {
+	c := Combiner{Folder: PolicyDeepest, Title: PolicyLast, Normalizer: &Normalizer{HTTPS: true}}
+	combined, duplicates := c.Combine(combineTestDocs()...)
+
+	// Without the other rules, the Go and HN feeds differ.
+	if tree := texts(combined.Body.Outlines); tree != "Tech(Go Systems(Rust blog) HN) Docs Misc(The Go Blog) HN" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if len(duplicates) != 1 || !reflect.DeepEqual(duplicates[0].Path, []int{0, 1, 0}) {
+		t.Errorf("Wrong duplicates: %+v", duplicates)
+	}
+
+	c = Combiner{Folder: PolicyLast}
+	combined, _ = c.Combine(combineTestDocs()...)
+	if tree := texts(combined.Body.Outlines); tree != "Docs Misc(Go) Tech(Systems(Rust)) HN" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotOPML is returned when the root element of a document is not <opml>.
+var ErrNotOPML = errors.New("opml: not an OPML document")
+
+// Node is an outline read by a Decoder, together with its position in the
+// outline tree.
+type Node struct {
+	// Outline holds the attributes of the outline and its unknown child
+	// elements. Its Outlines field is always empty: children are returned by
+	// subsequent calls to Next. Since the node is returned once its first
+	// child outline is reached, the unknown elements following a child
+	// outline are skipped.
+	Outline Outline
+
+	// Depth is 0 for top-level outlines, 1 for their children, and so on.
+	Depth int
+
+	// Path is the index path of the outline, starting from the top-level
+	// outlines of the body.
+	Path []int
+
+	// Ancestors holds the attributes of the enclosing outlines, from the
+	// top-level one down to the parent.
+	Ancestors []Outline
+
+	// Line and Column give the position of the outline in the input, both
+	// starting at 1. The column is counted in bytes.
+	Line, Column int
+}
+
+// A Decoder reads an OPML document from an input stream and yields its
+// outlines one at a time, so that memory usage does not depend on the size of
+// the document.
+type Decoder struct {
+	d         *xml.Decoder
+	lines     *lineReader
+	charset   string
+	version   string
+	head      *Head
+	positions map[string]inputPos // of the root, head, body and head children
+	decls     []xml.Attr
+	root      bool
+	body      bool
+	inBody    bool
+	done      bool
+	stack     []decoderFrame
+	top       int
+	pending   *Node // outline whose unknown child elements are being read
+}
+
+type decoderFrame struct {
+	outline  Outline
+	path     []int
+	decls    []xml.Attr
+	children int
+}
+
+// NewDecoder creates a new decoder reading from r. Documents that are not
+// encoded in UTF-8 are converted according to their byte order mark or XML
+// declaration.
+func NewDecoder(r io.Reader) *Decoder {
+	d := &Decoder{}
+	d.d, d.lines = newXMLDecoder(r, &d.charset)
+	return d
+}
+
+// Version returns the version of the document, reading its root element if
+// needed.
+func (d *Decoder) Version() (string, error) {
+	for !d.root && !d.done {
+		if _, err := d.step(); err != nil {
+			return "", err
+		}
+	}
+	return d.version, nil
+}
+
+// Head returns the head of the document, reading it if needed. It does not
+// consume any outline of the body. Head returns nil if the body starts before
+// any head element is found.
+func (d *Decoder) Head() (*Head, error) {
+	for d.head == nil && !d.inBody && !d.done {
+		if _, err := d.step(); err != nil {
+			return nil, err
+		}
+	}
+	return d.head, nil
+}
+
+// Next returns the next outline of the body, in document order. It returns
+// io.EOF once the body has been entirely read.
+func (d *Decoder) Next() (*Node, error) {
+	for !d.done {
+		n, err := d.step()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			return n, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// step processes a single token, returning a node once its unknown child
+// elements have been read, i.e. when the token starts or ends an outline of
+// the body.
+func (d *Decoder) step() (*Node, error) {
+	// The position is computed before reading the token, since the
+	// lineReader only knows the current line.
+	pos := d.position()
+	tok, err := d.d.Token()
+	if err == io.EOF {
+		d.done = true
+		if !d.root {
+			return nil, fmt.Errorf("%w: missing <opml> root element", ErrNotOPML)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case xml.StartElement:
+		switch {
+		case !d.root:
+			if t.Name.Local != "opml" {
+				d.done = true
+				return nil, fmt.Errorf("%w: unexpected root element <%s>", ErrNotOPML, t.Name.Local)
+			}
+			d.root = true
+			d.positions = map[string]inputPos{"opml": pos}
+			d.decls = declarations(nil, t.Attr)
+			for _, a := range t.Attr {
+				if a.Name.Space == "" && a.Name.Local == "version" {
+					d.version = a.Value
+				}
+			}
+		case d.inBody && t.Name.Local == "outline":
+			n := d.pending
+			d.pending = d.push(t, pos)
+			return n, nil
+		case d.pending != nil:
+			var e Element
+			if err := d.d.DecodeElement(&e, &t); err != nil {
+				return nil, err
+			}
+			elements := []Element{e}
+			unresolveElements(elements, d.stack[len(d.stack)-1].decls)
+			d.pending.Outline.Elements = append(d.pending.Outline.Elements, elements...)
+		case !d.inBody && t.Name.Local == "head":
+			if err := d.decodeHead(t, pos); err != nil {
+				return nil, err
+			}
+		case !d.inBody && t.Name.Local == "body":
+			d.positions["body"] = pos
+			d.body = true
+			d.inBody = true
+		default:
+			if err := d.d.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	case xml.EndElement:
+		switch {
+		case len(d.stack) > 0:
+			d.stack = d.stack[:len(d.stack)-1]
+			n := d.pending
+			d.pending = nil
+			return n, nil
+		case d.inBody:
+			d.inBody = false
+			d.done = true
+		default:
+			// End of the root element.
+			d.done = true
+		}
+	}
+
+	return nil, nil
+}
+
+// decodeHead decodes the head element, recording the positions of its
+// children.
+func (d *Decoder) decodeHead(start xml.StartElement, pos inputPos) error {
+	head := &Head{Attrs: start.Attr}
+	d.positions["head"] = pos
+	for {
+		pos := d.position()
+		tok, err := d.d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if f := head.field(t.Name); f != nil {
+				if err := d.d.DecodeElement(f, &t); err != nil {
+					return err
+				}
+				d.positions[t.Name.Local] = pos
+				continue
+			}
+			var e Element
+			if err := d.d.DecodeElement(&e, &t); err != nil {
+				return err
+			}
+			head.Elements = append(head.Elements, e)
+		case xml.EndElement:
+			head.unresolve(d.decls)
+			d.head = head
+			return nil
+		}
+	}
+}
+
+// inputPos is a position in the input, with line and column starting at 1.
+type inputPos struct {
+	line, col int
+}
+
+// position returns the position of the current input offset.
+func (d *Decoder) position() inputPos {
+	line, col := d.lines.position(d.d.InputOffset())
+	return inputPos{line, col}
+}
+
+func (d *Decoder) push(start xml.StartElement, pos inputPos) *Node {
+	var index int
+	var parentPath []int
+	decls := d.decls
+	if len(d.stack) > 0 {
+		parent := &d.stack[len(d.stack)-1]
+		index = parent.children
+		parent.children++
+		parentPath = parent.path
+		decls = parent.decls
+	} else {
+		index = d.top
+		d.top++
+	}
+
+	path := make([]int, len(parentPath)+1)
+	copy(path, parentPath)
+	path[len(parentPath)] = index
+
+	outline, decls := outlineFromStart(start, decls)
+	n := &Node{
+		Outline:   outline,
+		Depth:     len(d.stack),
+		Path:      path,
+		Ancestors: make([]Outline, len(d.stack)),
+	}
+	n.Line, n.Column = pos.line, pos.col
+	for i, f := range d.stack {
+		n.Ancestors[i] = f.outline
+	}
+
+	d.stack = append(d.stack, decoderFrame{outline: outline, path: path, decls: decls})
+	return n
+}
+
+// outlineFromStart returns an outline holding the attributes of the given
+// <outline> start element, along with the namespace declarations in scope
+// for its content.
+func outlineFromStart(start xml.StartElement, decls []xml.Attr) (Outline, []xml.Attr) {
+	var o Outline
+	decls = declarations(decls, start.Attr)
+	for _, a := range start.Attr {
+		if a.Name.Space == "" {
+			if f := o.knownAttr(a.Name.Local); f != nil {
+				*f = a.Value
+				continue
+			}
+		}
+		a.Name = unresolveName(a.Name, decls)
+		o.Attrs = append(o.Attrs, a)
+	}
+	return o, decls
+}
+
+// lineReader counts the lines of the data read through it. It implements
+// io.ByteReader, so that an xml.Decoder reading from it does not read ahead:
+// its input offset is at most one byte behind the data read through the
+// lineReader. Only the starts of the current and previous lines are kept, so
+// that memory usage does not depend on the size of the input.
+type lineReader struct {
+	r      *bufio.Reader
+	offset int64
+	line   int   // number of line breaks read
+	start  int64 // offset of the current line
+	prev   int64 // offset of the previous line
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			l.newline(l.offset + int64(i) + 1)
+		}
+	}
+	l.offset += int64(n)
+	return n, err
+}
+
+func (l *lineReader) ReadByte() (byte, error) {
+	b, err := l.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	l.offset++
+	if b == '\n' {
+		l.newline(l.offset)
+	}
+	return b, nil
+}
+
+func (l *lineReader) newline(start int64) {
+	l.line++
+	l.prev, l.start = l.start, start
+}
+
+// position returns the line and column of the given offset, both starting
+// at 1. The offset must be in the current or previous line.
+func (l *lineReader) position(offset int64) (int, int) {
+	if offset < l.start {
+		return l.line, int(offset-l.prev) + 1
+	}
+	return l.line + 1, int(offset-l.start) + 1
+}
@@ -0,0 +1,177 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nestedOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+	<head>
+		<title>Nested</title>
+		<ownerName>Kevin</ownerName>
+	</head>
+	<body>
+		<outline text="Tech">
+			<outline text="Go" type="rss" xmlUrl="http://blog.golang.org/feed.atom"/>
+			<outline text="Lang">
+				<outline text="Rust" type="rss" xmlUrl="https://blog.rust-lang.org/feed.xml"/>
+			</outline>
+		</outline>
+		<outline text="News" type="rss" xmlUrl="http://example.com/news.xml"/>
+	</body>
+</opml>`
+
+func TestDecoder(t *testing.T) {
+	d := NewDecoder(strings.NewReader(nestedOPML))
+
+	version, err := d.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "2.0" {
+		t.Errorf("Wrong OPML version: expected '2.0', found '%s'", version)
+	}
+
+	head, err := d.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head == nil || head.Title != "Nested" || head.OwnerName != "Kevin" {
+		t.Fatalf("Wrong head: %+v", head)
+	}
+
+	expected := []struct {
+		text      string
+		depth     int
+		path      []int
+		ancestors []string
+	}{
+		{"Tech", 0, []int{0}, []string{}},
+		{"Go", 1, []int{0, 0}, []string{"Tech"}},
+		{"Lang", 1, []int{0, 1}, []string{"Tech"}},
+		{"Rust", 2, []int{0, 1, 0}, []string{"Tech", "Lang"}},
+		{"News", 0, []int{1}, []string{}},
+	}
+
+	for _, e := range expected {
+		n, err := d.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error before outline '%s': %v", e.text, err)
+		}
+		if n.Outline.Text != e.text {
+			t.Errorf("Wrong outline text: expected '%s', found '%s'", e.text, n.Outline.Text)
+		}
+		if n.Depth != e.depth {
+			t.Errorf("Wrong depth for '%s': expected %d, found %d", e.text, e.depth, n.Depth)
+		}
+		if !reflect.DeepEqual(n.Path, e.path) {
+			t.Errorf("Wrong path for '%s': expected %v, found %v", e.text, e.path, n.Path)
+		}
+		ancestors := []string{}
+		for _, a := range n.Ancestors {
+			ancestors = append(ancestors, a.Text)
+		}
+		if !reflect.DeepEqual(ancestors, e.ancestors) {
+			t.Errorf("Wrong ancestors for '%s': expected %v, found %v",
+				e.text, e.ancestors, ancestors)
+		}
+	}
+
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, found %v", err)
+	}
+}
+
+func TestDecoderAttributes(t *testing.T) {
+	f, err := os.Open("../testdata/feeds.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	d := NewDecoder(f)
+	n, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Outline.XMLURL != "http://www.gilliek.ch/feeds" {
+		t.Errorf("Wrong outline XML URL: expected 'http://www.gilliek.ch/feeds', found '%s'",
+			n.Outline.XMLURL)
+	}
+
+	// The head is still available after the body has been reached.
+	head, err := d.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head == nil || head.Title != "Foobar" {
+		t.Errorf("Wrong head: %+v", head)
+	}
+}
+
+func TestDecoderInvalidRoot(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<rss version="2.0"></rss>`))
+	if _, err := d.Next(); err == nil {
+		t.Error("Expected failure!")
+	}
+}
+
+func TestDecoderElements(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<opml version="2.0" xmlns:podcast="https://podcastindex.org/namespace/1.0">
+	<body>
+		<outline text="Show" type="rss" xmlUrl="http://example.com/show.xml">
+			<podcast:funding url="http://example.com/donate">Support</podcast:funding>
+			<outline text="Episode 1"/>
+		</outline>
+		<outline text="News"/>
+	</body>
+</opml>`))
+
+	n, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Outline.XMLURL != "http://example.com/show.xml" || len(n.Outline.Elements) != 1 {
+		t.Fatalf("Wrong outline: %+v", n.Outline)
+	}
+	if e := n.Outline.Elements[0]; e.XMLName.Local != "podcast:funding" || e.Content != "Support" {
+		t.Errorf("Wrong element: %+v", e)
+	}
+
+	for _, text := range []string{"Episode 1", "News"} {
+		n, err := d.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Outline.Text != text || len(n.Outline.Elements) != 0 {
+			t.Errorf("Wrong outline: expected '%s', found %+v", text, n.Outline)
+		}
+	}
+	if _, err := d.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, found %v", err)
+	}
+}
+
+func TestNewOPMLFromReader(t *testing.T) {
+	f, err := os.Open("../testdata/feeds.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := NewOPMLFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDoc(t, doc)
+}
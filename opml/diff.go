@@ -0,0 +1,347 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind is the kind of an OutlineChange.
+type ChangeKind string
+
+// Kinds of outline changes.
+const (
+	Added    ChangeKind = "added"
+	Removed  ChangeKind = "removed"
+	Moved    ChangeKind = "moved" // reparented or reordered among its siblings
+	Modified ChangeKind = "modified"
+)
+
+// ValueChange is the change of the value of an attribute or head element.
+// An empty value stands for an unset one.
+type ValueChange struct {
+	Name     string
+	Old, New string
+}
+
+func (c ValueChange) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Name, c.Old, c.New)
+}
+
+// OutlineChange describes the change of an outline between two documents.
+// An outline that is both moved and modified is described by two changes.
+type OutlineChange struct {
+	Kind ChangeKind
+
+	// Key identifies the outline in both documents. See Compare.
+	Key string
+
+	// OldPath and NewPath are the index paths of the outline in the old and
+	// new documents. OldPath is nil for added outlines and NewPath is nil for
+	// removed ones.
+	OldPath, NewPath []int
+
+	// Outline holds the attributes of the outline, from the new document
+	// unless it was removed. Its Outlines field is always empty: children
+	// are described by their own changes.
+	Outline Outline
+
+	// Attrs lists the changed attributes of modified outlines.
+	Attrs []ValueChange
+}
+
+func (c OutlineChange) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %v %q", c.NewPath, c.Outline.Text)
+	case Removed:
+		return fmt.Sprintf("- %v %q", c.OldPath, c.Outline.Text)
+	case Moved:
+		return fmt.Sprintf("> %v -> %v %q", c.OldPath, c.NewPath, c.Outline.Text)
+	}
+	s := make([]string, len(c.Attrs))
+	for i, a := range c.Attrs {
+		s[i] = a.String()
+	}
+	return fmt.Sprintf("~ %v %q: %s", c.NewPath, c.Outline.Text, strings.Join(s, ", "))
+}
+
+// Diff describes the changes between two documents.
+type Diff struct {
+	// Head lists the changed head elements. Unknown head elements are
+	// compared by content.
+	Head []ValueChange
+
+	// Outlines lists the removed outlines, in the order of the old document,
+	// then the added, moved and modified outlines, in the order of the new
+	// document.
+	Outlines []OutlineChange
+}
+
+// Empty reports whether the documents compared are equivalent.
+func (d *Diff) Empty() bool {
+	return len(d.Head) == 0 && len(d.Outlines) == 0
+}
+
+// String returns the changes one per line.
+func (d *Diff) String() string {
+	var b strings.Builder
+	for _, c := range d.Head {
+		fmt.Fprintf(&b, "~ head %s\n", c)
+	}
+	for _, c := range d.Outlines {
+		fmt.Fprintln(&b, c)
+	}
+	return b.String()
+}
+
+// Compare returns the changes from document a to document b.
+//
+// Outlines are matched by key: the xmlUrl, url or htmlUrl attribute, in that
+// order of preference, prefixed by the name of the attribute, e.g.
+// "xmlUrl:https://go.dev/blog/feed.atom". Outlines without any of these
+// attributes are keyed by the texts of their ancestors and their own text,
+// e.g. "text:Tech/Go", so they are reported as removed and added when their
+// text changes or when they are reparented. When a key occurs several times
+// in a document, the occurrences after the first one are numbered, e.g.
+// "text:Tech/Go#2".
+//
+// A matched outline is moved when its parent changes, or when it has been
+// reordered among the siblings it keeps: the siblings that are not moved form
+// the longest common subsequence of both orders.
+//
+// Values are compared as is: documents can be compared in canonical form to
+// ignore formatting differences. See Canonical.
+func Compare(a, b *OPML) *Diff {
+	d := &Diff{Head: compareHead(&a.Head, &b.Head)}
+
+	oldEntries, oldKeys := diffIndex(a)
+	newEntries, newKeys := diffIndex(b)
+
+	for _, e := range oldEntries {
+		if newKeys[e.key] == nil {
+			d.Outlines = append(d.Outlines, OutlineChange{
+				Kind: Removed, Key: e.key, OldPath: e.path, Outline: e.attrs()})
+		}
+	}
+
+	reordered := reorderedKeys(oldEntries, newEntries, oldKeys, newKeys)
+	for _, e := range newEntries {
+		old := oldKeys[e.key]
+		if old == nil {
+			d.Outlines = append(d.Outlines, OutlineChange{
+				Kind: Added, Key: e.key, NewPath: e.path, Outline: e.attrs()})
+			continue
+		}
+		if old.parent != e.parent || reordered[e.key] {
+			d.Outlines = append(d.Outlines, OutlineChange{
+				Kind: Moved, Key: e.key, OldPath: old.path, NewPath: e.path, Outline: e.attrs()})
+		}
+		if attrs := compareAttrs(old.outline, e.outline); len(attrs) > 0 {
+			d.Outlines = append(d.Outlines, OutlineChange{
+				Kind: Modified, Key: e.key, OldPath: old.path, NewPath: e.path,
+				Outline: e.attrs(), Attrs: attrs})
+		}
+	}
+	return d
+}
+
+type diffEntry struct {
+	key     string
+	parent  string // key of the parent, empty for top-level outlines
+	prev    string // key of the previous sibling, empty for first children
+	path    []int
+	outline *Outline
+}
+
+// attrs returns a copy of the outline without its children.
+func (e *diffEntry) attrs() Outline {
+	o := *e.outline
+	o.Outlines = nil
+	return o
+}
+
+// diffIndex returns the outlines of doc in pre-order, along with their index
+// by key.
+func diffIndex(doc *OPML) ([]*diffEntry, map[string]*diffEntry) {
+	var entries []*diffEntry
+	index := make(map[string]*diffEntry)
+	counts := make(map[string]int)
+	var texts, keys []string
+
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		texts = append(texts[:depth], o.Text)
+		key := outlineKey(o, texts)
+		counts[key]++
+		if n := counts[key]; n > 1 {
+			key += "#" + strconv.Itoa(n)
+		}
+		e := &diffEntry{key: key, path: copyPath(path), outline: o}
+		if path[depth] > 0 {
+			e.prev = keys[depth]
+		}
+		keys = append(keys[:depth], key)
+
+		if depth > 0 {
+			e.parent = keys[depth-1]
+		}
+		entries = append(entries, e)
+		index[key] = e
+		return nil
+	})
+	return entries, index
+}
+
+// outlineKey returns the key of an outline, given the texts of its ancestors
+// and its own text.
+func outlineKey(o *Outline, texts []string) string {
+	switch {
+	case o.XMLURL != "":
+		return "xmlUrl:" + o.XMLURL
+	case o.URL != "":
+		return "url:" + o.URL
+	case o.HTMLURL != "":
+		return "htmlUrl:" + o.HTMLURL
+	}
+	return "text:" + strings.Join(texts, "/")
+}
+
+// reorderedKeys returns the keys of the outlines reordered among the siblings
+// they keep.
+func reorderedKeys(oldEntries, newEntries []*diffEntry, oldKeys, newKeys map[string]*diffEntry) map[string]bool {
+	// kept returns the children of each parent that keep the same parent,
+	// in order.
+	kept := func(entries []*diffEntry, other map[string]*diffEntry) map[string][]string {
+		children := make(map[string][]string)
+		for _, e := range entries {
+			if o := other[e.key]; o != nil && o.parent == e.parent {
+				children[e.parent] = append(children[e.parent], e.key)
+			}
+		}
+		return children
+	}
+	oldChildren := kept(oldEntries, newKeys)
+	newChildren := kept(newEntries, oldKeys)
+
+	reordered := make(map[string]bool)
+	for parent, keys := range newChildren {
+		common := longestCommonSubsequence(oldChildren[parent], keys)
+		for _, k := range keys {
+			if !common[k] {
+				reordered[k] = true
+			}
+		}
+	}
+	return reordered
+}
+
+// longestCommonSubsequence returns the elements of a longest common
+// subsequence of a and b, whose elements are unique.
+func longestCommonSubsequence(a, b []string) map[string]bool {
+	n, m := len(a), len(b)
+	lengths := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	common := make(map[string]bool)
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case a[i] == b[j]:
+			common[a[i]] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return common
+}
+
+// attrNames returns the names of the attributes of the outlines: the ones
+// mapped to fields of Outline, in the order of the fields, then the unknown
+// ones, sorted.
+func attrNames(outlines ...*Outline) []string {
+	var names []string
+	for _, a := range outlineAttrs {
+		names = append(names, a.name)
+	}
+	unknown := make(map[string]bool)
+	for _, o := range outlines {
+		for _, a := range o.Attrs {
+			unknown[attrName(a.Name)] = true
+		}
+	}
+	var sorted []string
+	for name := range unknown {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return append(names, sorted...)
+}
+
+// compareAttrs returns the changed attributes of an outline, in the order
+// of attrNames.
+func compareAttrs(a, b *Outline) []ValueChange {
+	var changes []ValueChange
+	for _, name := range attrNames(a, b) {
+		if before, after := a.Attr(name), b.Attr(name); before != after {
+			changes = append(changes, ValueChange{name, before, after})
+		}
+	}
+	return changes
+}
+
+// compareHead returns the changed head elements: the ones mapped to fields
+// of Head, in the order of the fields, then the unknown ones, in order of
+// appearance.
+func compareHead(a, b *Head) []ValueChange {
+	var changes []ValueChange
+	for _, e := range headElements {
+		if before, after := *e.field(a), *e.field(b); before != after {
+			changes = append(changes, ValueChange{e.name, before, after})
+		}
+	}
+
+	contents := func(h *Head) map[string]string {
+		m := make(map[string]string)
+		for _, e := range h.Elements {
+			name := attrName(e.XMLName)
+			if _, ok := m[name]; !ok {
+				m[name] = e.Content
+			}
+		}
+		return m
+	}
+	before, after := contents(a), contents(b)
+	seen := make(map[string]bool)
+	for _, elements := range [][]Element{a.Elements, b.Elements} {
+		for _, e := range elements {
+			name := attrName(e.XMLName)
+			if !seen[name] && before[name] != after[name] {
+				changes = append(changes, ValueChange{name, before[name], after[name]})
+			}
+			seen[name] = true
+		}
+	}
+	return changes
+}
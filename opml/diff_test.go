@@ -0,0 +1,94 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func feed(text string, children ...Outline) Outline {
+	return Outline{Text: text, XMLURL: "http://example.com/" + text, Outlines: children}
+}
+
+func folder(text string, children ...Outline) Outline {
+	return Outline{Text: text, Outlines: children}
+}
+
+func TestCompare(t *testing.T) {
+	a := &OPML{
+		Head: Head{Title: "A"},
+		Body: Body{Outlines: []Outline{
+			folder("Tech", feed("Go"), feed("Rust"), feed("Py")),
+			folder("News", feed("HN")),
+			folder("Note"),
+		}},
+	}
+	b := a.Clone()
+	b.Head.Title = "B"
+	b.Remove([]int{2})
+	b.Move([]int{0, 1}, []int{1, 1})
+	b.Move([]int{0, 1}, []int{0, 0})
+	b.Insert([]int{0, 2}, feed("Zig"))
+	b.Body.Outlines[0].Outlines[1].Title = "Golang"
+
+	d := Compare(a, b)
+	expected := `~ head title: "A" -> "B"
+- [2] "Note"
+> [0 0] -> [0 1] "Go"
+~ [0 1] "Go": title: "" -> "Golang"
++ [0 2] "Zig"
+> [0 1] -> [1 1] "Rust"
+`
+	if s := d.String(); s != expected {
+		t.Errorf("Wrong diff: expected\n%s\nfound\n%s", expected, s)
+	}
+
+	c := d.Outlines[2]
+	if c.Kind != Modified || c.Key != "xmlUrl:http://example.com/Go" ||
+		!reflect.DeepEqual(c.OldPath, []int{0, 0}) || !reflect.DeepEqual(c.NewPath, []int{0, 1}) {
+		t.Errorf("Wrong change: %+v", c)
+	}
+
+	if d := Compare(a, a.Clone()); !d.Empty() {
+		t.Errorf("Unexpected changes:\n%s", d)
+	}
+}
+
+func TestCompareKeys(t *testing.T) {
+	a := &OPML{Body: Body{Outlines: []Outline{
+		folder("Dup"),
+		folder("Dup", Outline{Text: "Page", URL: "http://example.com/page"}),
+	}}}
+	b := a.Clone()
+	b.Body.Outlines[0].Outlines = []Outline{{Text: "Site", HTMLURL: "http://example.com/"}}
+	b.Body.Outlines[1].Outlines[0].Attrs = []xml.Attr{{Name: xml.Name{Local: "p:x"}, Value: "1"}}
+
+	d := Compare(a, b)
+	if len(d.Outlines) != 2 {
+		t.Fatalf("Wrong number of changes:\n%s", d)
+	}
+	if c := d.Outlines[0]; c.Kind != Added || c.Key != "htmlUrl:http://example.com/" {
+		t.Errorf("Wrong change: %+v", c)
+	}
+	if c := d.Outlines[1]; c.Kind != Modified || c.Key != "url:http://example.com/page" ||
+		!reflect.DeepEqual(c.Attrs, []ValueChange{{"p:x", "", "1"}}) {
+		t.Errorf("Wrong change: %+v", c)
+	}
+
+	// The second folder is matched as the second occurrence of its key.
+	b.Body.Outlines[1].Text = "Other"
+	d = Compare(a, b)
+	var keys []string
+	for _, c := range d.Outlines {
+		if c.Kind == Removed {
+			keys = append(keys, c.Key)
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"text:Dup#2"}) {
+		t.Errorf("Wrong removed outlines: %v", keys)
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPath is returned when an index path does not address an outline of
+// the document.
+var ErrInvalidPath = errors.New("opml: invalid outline path")
+
+// ErrInvalidMove is returned when an outline cannot be moved as requested,
+// e.g. when moving up the first outline of a list.
+var ErrInvalidMove = errors.New("opml: invalid outline move")
+
+// The editing functions below address outlines by index path: []int{0, 3, 1}
+// is the second child of the fourth child of the first top-level outline.
+// They keep the expansion state of the head consistent with the edits.
+
+// Outline returns the outline at the given path.
+func (doc *OPML) Outline(path []int) (*Outline, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPath, path)
+	}
+	list, err := doc.children(path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	i := path[len(path)-1]
+	if i < 0 || i >= len(*list) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPath, path)
+	}
+	return &(*list)[i], nil
+}
+
+// Insert inserts o at the given path. The last index of the path may be equal
+// to the number of children of the parent, to append o.
+func (doc *OPML) Insert(path []int, o Outline) error {
+	exp := doc.expansion()
+	if err := doc.insert(path, o); err != nil {
+		return err
+	}
+	if exp != nil {
+		exp.insert(path, nil)
+		doc.setExpansion(exp)
+	}
+	return nil
+}
+
+// Remove removes the outline at the given path and returns it.
+func (doc *OPML) Remove(path []int) (Outline, error) {
+	exp := doc.expansion()
+	o, err := doc.remove(path)
+	if err != nil {
+		return Outline{}, err
+	}
+	if exp != nil {
+		exp.remove(path)
+		doc.setExpansion(exp)
+	}
+	return o, nil
+}
+
+// Move moves the outline at path from to path to. The destination path is
+// interpreted once the outline has been removed from its original position.
+func (doc *OPML) Move(from, to []int) error {
+	exp := doc.expansion()
+	o, err := doc.remove(from)
+	if err != nil {
+		return err
+	}
+	if err := doc.insert(to, o); err != nil {
+		// Restore the document as it was, from being a valid path.
+		doc.insert(from, o)
+		return err
+	}
+	if exp != nil {
+		moved := exp.remove(from)
+		exp.insert(to, moved)
+		doc.setExpansion(exp)
+	}
+	return nil
+}
+
+// MoveUp swaps the outline at path with its previous sibling and returns its
+// new path.
+func (doc *OPML) MoveUp(path []int) ([]int, error) {
+	if _, err := doc.Outline(path); err != nil {
+		return nil, err
+	}
+	i := path[len(path)-1]
+	if i == 0 {
+		return nil, fmt.Errorf("%w: cannot move up first outline %v", ErrInvalidMove, path)
+	}
+	to := sibling(path, i-1)
+	return to, doc.Move(path, to)
+}
+
+// MoveDown swaps the outline at path with its next sibling and returns its new
+// path.
+func (doc *OPML) MoveDown(path []int) ([]int, error) {
+	if _, err := doc.Outline(path); err != nil {
+		return nil, err
+	}
+	list, _ := doc.children(path[:len(path)-1])
+	i := path[len(path)-1]
+	if i == len(*list)-1 {
+		return nil, fmt.Errorf("%w: cannot move down last outline %v", ErrInvalidMove, path)
+	}
+	to := sibling(path, i+1)
+	return to, doc.Move(path, to)
+}
+
+// Indent makes the outline at path the last child of its previous sibling and
+// returns its new path.
+func (doc *OPML) Indent(path []int) ([]int, error) {
+	if _, err := doc.Outline(path); err != nil {
+		return nil, err
+	}
+	i := path[len(path)-1]
+	if i == 0 {
+		return nil, fmt.Errorf("%w: cannot indent first outline %v", ErrInvalidMove, path)
+	}
+	return doc.Reparent(path, sibling(path, i-1))
+}
+
+// Outdent makes the outline at path the next sibling of its parent and
+// returns its new path.
+func (doc *OPML) Outdent(path []int) ([]int, error) {
+	if _, err := doc.Outline(path); err != nil {
+		return nil, err
+	}
+	if len(path) == 1 {
+		return nil, fmt.Errorf("%w: cannot outdent top-level outline %v", ErrInvalidMove, path)
+	}
+	parent := path[:len(path)-1]
+	to := sibling(parent, parent[len(parent)-1]+1)
+	return to, doc.Move(path, to)
+}
+
+// Reparent makes the outline at path the last child of the outline at path
+// parent, or the last top-level outline if parent is empty, and returns its
+// new path.
+func (doc *OPML) Reparent(path, parent []int) ([]int, error) {
+	if _, err := doc.Outline(path); err != nil {
+		return nil, err
+	}
+	if len(parent) > 0 {
+		if _, err := doc.Outline(parent); err != nil {
+			return nil, err
+		}
+	}
+	newParent, ok := adjustForRemoval(parent, path)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot move %v into its own subtree", ErrInvalidMove, path)
+	}
+
+	// Once the outline is removed, the new parent is still at the same
+	// position or one before.
+	var to []int
+	if len(newParent) == 0 {
+		to = []int{len(doc.Body.Outlines)}
+	} else {
+		p, _ := doc.Outline(parent)
+		to = append(copyPath(newParent), len(p.Outlines))
+	}
+	if isChild(path, newParent) {
+		// The outline is already a child of the new parent.
+		to[len(to)-1]--
+	}
+	return to, doc.Move(path, to)
+}
+
+// children returns a pointer to the list of children of the outline at the
+// given path, or to the top-level outlines if the path is empty.
+func (doc *OPML) children(path []int) (*[]Outline, error) {
+	if len(path) == 0 {
+		return &doc.Body.Outlines, nil
+	}
+	o, err := doc.Outline(path)
+	if err != nil {
+		return nil, err
+	}
+	return &o.Outlines, nil
+}
+
+func (doc *OPML) insert(path []int, o Outline) error {
+	if len(path) == 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidPath, path)
+	}
+	list, err := doc.children(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	i := path[len(path)-1]
+	if i < 0 || i > len(*list) {
+		return fmt.Errorf("%w: %v", ErrInvalidPath, path)
+	}
+	*list = append(*list, Outline{})
+	copy((*list)[i+1:], (*list)[i:])
+	(*list)[i] = o
+	return nil
+}
+
+func (doc *OPML) remove(path []int) (Outline, error) {
+	if _, err := doc.Outline(path); err != nil {
+		return Outline{}, err
+	}
+	list, _ := doc.children(path[:len(path)-1])
+	i := path[len(path)-1]
+	o := (*list)[i]
+	*list = append((*list)[:i], (*list)[i+1:]...)
+	return o, nil
+}
+
+// sibling returns the path of the sibling at index i of the outline at path.
+func sibling(path []int, i int) []int {
+	s := copyPath(path)
+	s[len(s)-1] = i
+	return s
+}
+
+func copyPath(path []int) []int {
+	c := make([]int, len(path))
+	copy(c, path)
+	return c
+}
+
+// hasPrefix reports whether path starts with prefix.
+func hasPrefix(path, prefix []int) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isChild reports whether path is a child of parent.
+func isChild(path, parent []int) bool {
+	return len(path) == len(parent)+1 && hasPrefix(path, parent)
+}
+
+// adjustForRemoval returns the path p once the outline at path removed has
+// been removed. It returns false if p is within the removed subtree.
+func adjustForRemoval(p, removed []int) ([]int, bool) {
+	if hasPrefix(p, removed) {
+		return nil, false
+	}
+	n := len(removed) - 1
+	if len(p) > n && hasPrefix(p, removed[:n]) && p[n] > removed[n] {
+		p = copyPath(p)
+		p[n]--
+	}
+	return p, true
+}
+
+// adjustForInsertion returns the path p once an outline has been inserted at
+// path inserted.
+func adjustForInsertion(p, inserted []int) []int {
+	n := len(inserted) - 1
+	if len(p) > n && hasPrefix(p, inserted[:n]) && p[n] >= inserted[n] {
+		p = copyPath(p)
+		p[n]++
+	}
+	return p
+}
+
+// expansionPaths holds the paths of the expanded outlines of a document.
+type expansionPaths [][]int
+
+// remove updates the paths for the removal of the outline at path and
+// returns the paths of the removed expanded outlines, relative to the removed
+// one.
+func (e *expansionPaths) remove(path []int) [][]int {
+	var kept, removed [][]int
+	for _, p := range *e {
+		if q, ok := adjustForRemoval(p, path); ok {
+			kept = append(kept, q)
+		} else {
+			removed = append(removed, p[len(path)-1:])
+		}
+	}
+	*e = kept
+	return removed
+}
+
+// insert updates the paths for the insertion of an outline at path, whose
+// expanded outlines are given relative to it.
+func (e *expansionPaths) insert(path []int, expanded [][]int) {
+	for i, p := range *e {
+		(*e)[i] = adjustForInsertion(p, path)
+	}
+	for _, rel := range expanded {
+		p := append(copyPath(path[:len(path)-1]), rel...)
+		p[len(path)-1] = path[len(path)-1]
+		*e = append(*e, p)
+	}
+}
+
+// expansion returns the paths of the expanded outlines, or nil if the
+// document has no valid expansion state.
+func (doc *OPML) expansion() *expansionPaths {
+	lines, err := doc.Head.Expansion()
+	if err != nil || len(lines) == 0 {
+		return nil
+	}
+
+	// Per the specification, each line number tells how many times to
+	// navigate "flatdown" from the first summit before expanding, with
+	// previous expansions applied.
+	e := expansionPaths{}
+	for _, line := range lines {
+		n := 0
+		doc.visible(&e, func(o *Outline, path []int) bool {
+			n++
+			if n == line {
+				e = append(e, copyPath(path))
+				return false
+			}
+			return true
+		})
+	}
+	return &e
+}
+
+// setExpansion sets the expansion state of the document from the paths of
+// its expanded outlines.
+func (doc *OPML) setExpansion(e *expansionPaths) {
+	var lines []int
+	n := 0
+	doc.visible(e, func(o *Outline, path []int) bool {
+		n++
+		if e.contains(path) {
+			lines = append(lines, n)
+		}
+		return true
+	})
+	doc.Head.SetExpansion(lines)
+}
+
+func (e *expansionPaths) contains(path []int) bool {
+	for _, p := range *e {
+		if len(p) == len(path) && hasPrefix(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// visible calls fn for each visible outline, in order, until it returns
+// false. An outline is visible if all its ancestors are expanded.
+func (doc *OPML) visible(e *expansionPaths, fn func(o *Outline, path []int) bool) {
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		if !fn(o, path) {
+			return Stop
+		}
+		if !e.contains(path) {
+			return SkipChildren
+		}
+		return nil
+	})
+}
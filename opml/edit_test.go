@@ -0,0 +1,173 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// texts returns a compact representation of the outline tree, e.g.
+// "Tech(Go Lang(Rust)) News".
+func texts(outlines []Outline) string {
+	s := make([]string, len(outlines))
+	for i, o := range outlines {
+		s[i] = o.Text
+		if len(o.Outlines) > 0 {
+			s[i] += "(" + texts(o.Outlines) + ")"
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func editTestDoc(t *testing.T) *OPML {
+	doc := walkTestDoc(t)
+	doc.Head.ExpansionState = "1, 3"
+	return doc
+}
+
+func TestOutlineAt(t *testing.T) {
+	doc := editTestDoc(t)
+
+	o, err := doc.Outline([]int{0, 1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Text != "Rust" {
+		t.Errorf("Wrong outline: expected 'Rust', found '%s'", o.Text)
+	}
+
+	for _, path := range [][]int{nil, {2}, {0, 2}, {1, 0}, {-1}} {
+		if _, err := doc.Outline(path); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Expected ErrInvalidPath for %v, found %v", path, err)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name      string
+		edit      func(doc *OPML) ([]int, error)
+		path      []int
+		tree      string
+		expansion string
+	}{
+		{
+			"insert",
+			func(doc *OPML) ([]int, error) {
+				return nil, doc.Insert([]int{0, 0}, Outline{Text: "C"})
+			},
+			nil, "Tech(C Go Lang(Rust)) News", "1, 4",
+		},
+		{
+			"remove",
+			func(doc *OPML) ([]int, error) {
+				_, err := doc.Remove([]int{0, 0})
+				return nil, err
+			},
+			nil, "Tech(Lang(Rust)) News", "1, 2",
+		},
+		{
+			"move up",
+			func(doc *OPML) ([]int, error) { return doc.MoveUp([]int{1}) },
+			[]int{0}, "News Tech(Go Lang(Rust))", "2, 4",
+		},
+		{
+			"move down",
+			func(doc *OPML) ([]int, error) { return doc.MoveDown([]int{0, 0}) },
+			[]int{0, 1}, "Tech(Lang(Rust) Go) News", "1, 2",
+		},
+		{
+			"indent",
+			func(doc *OPML) ([]int, error) { return doc.Indent([]int{1}) },
+			[]int{0, 2}, "Tech(Go Lang(Rust) News)", "1, 3",
+		},
+		{
+			"outdent",
+			func(doc *OPML) ([]int, error) { return doc.Outdent([]int{0, 1, 0}) },
+			[]int{0, 2}, "Tech(Go Lang Rust) News", "1, 3",
+		},
+		{
+			"reparent",
+			func(doc *OPML) ([]int, error) { return doc.Reparent([]int{1}, []int{0, 1}) },
+			[]int{0, 1, 1}, "Tech(Go Lang(Rust News))", "1, 3",
+		},
+		{
+			"reparent under collapsed",
+			func(doc *OPML) ([]int, error) { return doc.Reparent([]int{0, 1}, []int{1}) },
+			[]int{1, 0}, "Tech(Go) News(Lang(Rust))", "1",
+		},
+		{
+			"reparent top-level",
+			func(doc *OPML) ([]int, error) { return doc.Reparent([]int{0, 1}, nil) },
+			[]int{2}, "Tech(Go) News Lang(Rust)", "1, 4",
+		},
+		{
+			"move",
+			func(doc *OPML) ([]int, error) {
+				return nil, doc.Move([]int{1}, []int{0, 0})
+			},
+			nil, "Tech(News Go Lang(Rust))", "1, 4",
+		},
+	}
+
+	for _, test := range tests {
+		doc := editTestDoc(t)
+		path, err := test.edit(doc)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(path, test.path) {
+			t.Errorf("%s: wrong path: expected %v, found %v", test.name, test.path, path)
+		}
+		if tree := texts(doc.Body.Outlines); tree != test.tree {
+			t.Errorf("%s: wrong tree: expected '%s', found '%s'", test.name, test.tree, tree)
+		}
+		if doc.Head.ExpansionState != test.expansion {
+			t.Errorf("%s: wrong expansion state: expected '%s', found '%s'",
+				test.name, test.expansion, doc.Head.ExpansionState)
+		}
+	}
+}
+
+func TestEditFailure(t *testing.T) {
+	doc := editTestDoc(t)
+
+	if _, err := doc.MoveUp([]int{0}); !errors.Is(err, ErrInvalidMove) {
+		t.Errorf("Expected ErrInvalidMove, found %v", err)
+	}
+	if _, err := doc.MoveDown([]int{1}); !errors.Is(err, ErrInvalidMove) {
+		t.Errorf("Expected ErrInvalidMove, found %v", err)
+	}
+	if _, err := doc.Indent([]int{0, 0}); !errors.Is(err, ErrInvalidMove) {
+		t.Errorf("Expected ErrInvalidMove, found %v", err)
+	}
+	if _, err := doc.Outdent([]int{1}); !errors.Is(err, ErrInvalidMove) {
+		t.Errorf("Expected ErrInvalidMove, found %v", err)
+	}
+	if _, err := doc.Reparent([]int{0}, []int{0, 1}); !errors.Is(err, ErrInvalidMove) {
+		t.Errorf("Expected ErrInvalidMove, found %v", err)
+	}
+	if err := doc.Insert([]int{0, 5}, Outline{Text: "X"}); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Expected ErrInvalidPath, found %v", err)
+	}
+	if _, err := doc.Remove([]int{3}); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Expected ErrInvalidPath, found %v", err)
+	}
+	if err := doc.Move([]int{0}, []int{3}); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Expected ErrInvalidPath, found %v", err)
+	}
+
+	// Failed edits leave the document untouched.
+	if tree := texts(doc.Body.Outlines); tree != "Tech(Go Lang(Rust)) News" {
+		t.Errorf("Document modified by failed edits: '%s'", tree)
+	}
+	if doc.Head.ExpansionState != "1, 3" {
+		t.Errorf("Expansion state modified by failed edits: '%s'", doc.Head.ExpansionState)
+	}
+}
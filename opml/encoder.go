@@ -0,0 +1,247 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// An Encoder writes OPML documents to an output stream. By default, it
+// writes the XML declaration, indents elements with tabs and writes empty
+// outlines with an end tag, like XML does.
+type Encoder struct {
+	w          io.Writer
+	indent     string
+	compact    bool
+	selfClose  bool
+	attrOrder  []string
+	omitHeader bool
+
+	out        *bufio.Writer
+	err        error
+	depth      int
+	indentedIn bool
+	putNewline bool
+}
+
+// NewEncoder creates a new encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, indent: "\t"}
+}
+
+// SetIndent sets the string written once per nesting level before each
+// element. An empty indent still writes each element on its own line.
+func (e *Encoder) SetIndent(indent string) {
+	e.indent = indent
+}
+
+// SetCompact sets whether elements are written without any whitespace in
+// between, in which case the indent is ignored.
+func (e *Encoder) SetCompact(compact bool) {
+	e.compact = compact
+}
+
+// SetSelfClosing sets whether outlines without children are written as
+// self-closing tags, e.g. <outline text="Go"/>.
+func (e *Encoder) SetSelfClosing(selfClose bool) {
+	e.selfClose = selfClose
+}
+
+// SetAttrOrder sets the attributes written first on outlines, in the given
+// order. The other attributes follow in the default order: the ones mapped to
+// fields of Outline, in the order of the fields, then the ones kept in
+// Outline.Attrs. Prefixed attributes are named as written in the document,
+// e.g. "podcast:guid".
+func (e *Encoder) SetAttrOrder(names ...string) {
+	e.attrOrder = names
+}
+
+// SetOmitHeader sets whether the XML declaration is omitted.
+func (e *Encoder) SetOmitHeader(omit bool) {
+	e.omitHeader = omit
+}
+
+// Encode writes doc to the stream, in the encoding given by doc.Encoding.
+func (e *Encoder) Encode(doc *OPML) error {
+	w, err := encodeCharset(e.w, doc.Encoding)
+	if err != nil {
+		return err
+	}
+	e.out = bufio.NewWriter(w)
+	e.err = nil
+	e.depth = 0
+	e.indentedIn = false
+	e.putNewline = false
+
+	if !e.omitHeader {
+		header := xmlHeader(doc.Encoding)
+		if e.compact {
+			header = strings.TrimSuffix(header, "\n")
+		}
+		e.write(header)
+	}
+
+	attrs := []xml.Attr{{Name: xml.Name{Local: "version"}, Value: doc.Version}}
+	e.start("opml", append(attrs, doc.Attrs...))
+	e.head(&doc.Head)
+	e.start("body", nil)
+	for i := range doc.Body.Outlines {
+		e.outline(&doc.Body.Outlines[i])
+	}
+	e.end("body")
+	e.end("opml")
+
+	if e.err == nil {
+		e.err = e.out.Flush()
+	}
+	if err := w.Close(); e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+func (e *Encoder) head(h *Head) {
+	e.start("head", h.Attrs)
+	for _, f := range headElements {
+		value := *f.field(h)
+		if value == "" && f.name != "title" {
+			continue
+		}
+		e.start(f.name, nil)
+		e.text(value)
+		e.end(f.name)
+	}
+	e.elements(h.Elements)
+	e.end("head")
+}
+
+func (e *Encoder) outline(o *Outline) {
+	attrs := e.outlineAttrs(o)
+	if e.selfClose && len(o.Outlines) == 0 && len(o.Elements) == 0 {
+		e.indentStart()
+		e.write("<outline")
+		e.attrs(attrs)
+		e.write("/>")
+		e.depth--
+		e.indentedIn = false
+		return
+	}
+
+	e.start("outline", attrs)
+	for i := range o.Outlines {
+		e.outline(&o.Outlines[i])
+	}
+	e.elements(o.Elements)
+	e.end("outline")
+}
+
+// outlineAttrs returns the attributes of o in the order they are written.
+// The text attribute is always written, even if empty.
+func (e *Encoder) outlineAttrs(o *Outline) []xml.Attr {
+	attrs := make([]xml.Attr, 0, len(outlineAttrs)+len(o.Attrs))
+	for _, a := range outlineAttrs {
+		if value := *a.field(o); value != "" || a.name == "text" {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: a.name}, Value: value})
+		}
+	}
+	attrs = append(attrs, o.Attrs...)
+	if len(e.attrOrder) == 0 {
+		return attrs
+	}
+
+	ordered := make([]xml.Attr, 0, len(attrs))
+	for _, name := range e.attrOrder {
+		for i, a := range attrs {
+			if a.Name.Local != "" && attrName(a.Name) == name {
+				ordered = append(ordered, a)
+				attrs[i].Name = xml.Name{}
+				break
+			}
+		}
+	}
+	for _, a := range attrs {
+		if a.Name.Local != "" {
+			ordered = append(ordered, a)
+		}
+	}
+	return ordered
+}
+
+func (e *Encoder) elements(elements []Element) {
+	for _, el := range elements {
+		if el.XMLName.Local == "" {
+			continue
+		}
+		name := attrName(el.XMLName)
+		e.start(name, el.Attrs)
+		e.write(el.Content)
+		e.end(name)
+	}
+}
+
+// The methods below indent the output the same way as xml.MarshalIndent: an
+// end tag is written on the line of its start tag when no element was written
+// in between.
+
+func (e *Encoder) start(name string, attrs []xml.Attr) {
+	e.indentStart()
+	e.write("<" + name)
+	e.attrs(attrs)
+	e.write(">")
+}
+
+func (e *Encoder) end(name string) {
+	e.depth--
+	if e.indentedIn {
+		e.indentedIn = false
+	} else {
+		e.newline()
+	}
+	e.write("</" + name + ">")
+}
+
+func (e *Encoder) indentStart() {
+	e.newline()
+	e.depth++
+	e.indentedIn = true
+}
+
+func (e *Encoder) newline() {
+	if e.compact {
+		return
+	}
+	if e.putNewline {
+		e.write("\n")
+	} else {
+		e.putNewline = true
+	}
+	e.write(strings.Repeat(e.indent, e.depth))
+}
+
+func (e *Encoder) attrs(attrs []xml.Attr) {
+	for _, a := range attrs {
+		if a.Name.Local == "" {
+			continue
+		}
+		e.write(" " + attrName(a.Name) + `="`)
+		e.text(a.Value)
+		e.write(`"`)
+	}
+}
+
+func (e *Encoder) text(s string) {
+	if e.err == nil {
+		e.err = xml.EscapeText(e.out, []byte(s))
+	}
+}
+
+func (e *Encoder) write(s string) {
+	if e.err == nil {
+		_, e.err = e.out.WriteString(s)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestEncoderDefault(t *testing.T) {
+	b, err := ioutil.ReadFile("../testdata/feeds.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := NewOPML(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The default output is the one of xml.MarshalIndent.
+	expected, err := xml.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	if output := buf.String(); output != xml.Header+string(expected) {
+		t.Errorf("Invalid generated OPML: expected\n\n%s\n\nfound\n\n%s", expected, output)
+	}
+}
+
+func encoderTestDoc() *OPML {
+	return &OPML{
+		Version: "2.0",
+		Head:    Head{Title: "Feeds & Co"},
+		Body: Body{Outlines: []Outline{
+			{Text: "Tech", Outlines: []Outline{
+				{Text: "Go", Type: "rss", XMLURL: "https://go.dev/blog/feed.atom",
+					Attrs: []xml.Attr{{Name: xml.Name{Local: "podcast:guid"}, Value: "42"}}},
+			}},
+			{Text: `Say "hi"`},
+		}},
+	}
+}
+
+func TestEncoderOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(e *Encoder)
+		expected string
+	}{
+		{
+			"self-closing",
+			func(e *Encoder) { e.SetSelfClosing(true) },
+			`<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+	<head>
+		<title>Feeds &amp; Co</title>
+	</head>
+	<body>
+		<outline text="Tech">
+			<outline text="Go" type="rss" xmlUrl="https://go.dev/blog/feed.atom" podcast:guid="42"/>
+		</outline>
+		<outline text="Say &#34;hi&#34;"/>
+	</body>
+</opml>`,
+		},
+		{
+			"indent",
+			func(e *Encoder) { e.SetIndent("  ") },
+			`<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head>
+    <title>Feeds &amp; Co</title>
+  </head>
+  <body>
+    <outline text="Tech">
+      <outline text="Go" type="rss" xmlUrl="https://go.dev/blog/feed.atom" podcast:guid="42"></outline>
+    </outline>
+    <outline text="Say &#34;hi&#34;"></outline>
+  </body>
+</opml>`,
+		},
+		{
+			"compact",
+			func(e *Encoder) { e.SetCompact(true) },
+			`<?xml version="1.0" encoding="UTF-8"?>` +
+				`<opml version="2.0"><head><title>Feeds &amp; Co</title></head><body>` +
+				`<outline text="Tech"><outline text="Go" type="rss" xmlUrl="https://go.dev/blog/feed.atom" podcast:guid="42"></outline></outline>` +
+				`<outline text="Say &#34;hi&#34;"></outline></body></opml>`,
+		},
+		{
+			"attribute order and no header",
+			func(e *Encoder) {
+				e.SetCompact(true)
+				e.SetSelfClosing(true)
+				e.SetOmitHeader(true)
+				e.SetAttrOrder("type", "podcast:guid", "missing")
+			},
+			`<opml version="2.0"><head><title>Feeds &amp; Co</title></head><body>` +
+				`<outline text="Tech"><outline type="rss" podcast:guid="42" text="Go" xmlUrl="https://go.dev/blog/feed.atom"/></outline>` +
+				`<outline text="Say &#34;hi&#34;"/></body></opml>`,
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		test.setup(e)
+		if err := e.Encode(encoderTestDoc()); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if output := buf.String(); output != test.expected {
+			t.Errorf("%s: invalid generated OPML: expected\n\n%s\n\nfound\n\n%s",
+				test.name, test.expected, output)
+		}
+	}
+}
+
+func TestEncoderCharset(t *testing.T) {
+	doc := encoderTestDoc()
+	doc.Head.Title = "Café €"
+	doc.Encoding = "ISO-8859-1"
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetCompact(true)
+	if err := e.Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	if !strings.HasPrefix(output, `<?xml version="1.0" encoding="ISO-8859-1"?><opml`) {
+		t.Errorf("Wrong XML declaration: %s", output)
+	}
+	if !strings.Contains(output, "<title>Caf\xe9 &#8364;</title>") {
+		t.Errorf("Wrong encoded title: %q", output)
+	}
+}
+
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	if err := NewEncoder(failingWriter{}).Encode(encoderTestDoc()); err != errWrite {
+		t.Errorf("Wrong error: expected %v, found %v", errWrite, err)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodySize is the maximum size of the documents read by a Fetcher
+// whose MaxBodySize is 0.
+const DefaultMaxBodySize = 32 << 20
+
+// DefaultAccept is the Accept header sent by a Fetcher whose Accept is empty.
+const DefaultAccept = "text/x-opml, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.1"
+
+// ErrBodyTooLarge is returned when a document exceeds the maximum size
+// allowed by a Fetcher.
+var ErrBodyTooLarge = errors.New("opml: response body too large")
+
+// HTTPError is returned when a server answers with a non-2xx status code.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string // e.g. "404 Not Found"
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("opml: GET %s: %s", e.URL, e.Status)
+}
+
+// A Fetcher retrieves OPML documents over HTTP. The zero value is ready to
+// use.
+type Fetcher struct {
+	// Client is the HTTP client used for requests. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// MaxBodySize is the maximum size of a document, in bytes. If 0,
+	// DefaultMaxBodySize is used. If negative, the size is not limited.
+	MaxBodySize int64
+
+	// Accept is the Accept header sent with requests. If empty,
+	// DefaultAccept is used.
+	Accept string
+
+	// UserAgent is the User-Agent header sent with requests. If empty, the
+	// default one of the net/http package is used.
+	UserAgent string
+
+	// Cache stores the retrieved documents along with their ETag and
+	// Last-Modified headers. If set, requests are made conditional and
+	// unchanged documents are read from the cache.
+	Cache Cache
+}
+
+// Result is the outcome of a request made by a Fetcher.
+type Result struct {
+	OPML *OPML
+
+	// NotModified is true if the server answered that the document has not
+	// changed since it was cached. OPML is then parsed from the cache.
+	NotModified bool
+
+	// ETag and LastModified hold the validators sent by the server.
+	ETag         string
+	LastModified string
+}
+
+// Fetch retrieves and parses the OPML document at the given URL. It returns an
+// *HTTPError if the server does not answer with a 2xx status code.
+func (f *Fetcher) Fetch(ctx context.Context, url string) (*OPML, error) {
+	res, err := f.Get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return res.OPML, nil
+}
+
+// Get retrieves and parses the OPML document at the given URL, like Fetch,
+// and reports whether it has changed since it was cached. Gzip and deflate
+// content encodings are handled transparently.
+func (f *Fetcher) Get(ctx context.Context, url string) (*Result, error) {
+	var entry *CacheEntry
+	header := http.Header{"Accept-Encoding": {"gzip, deflate"}}
+	if f.Cache != nil {
+		var err error
+		if entry, err = f.Cache.Get(url); err != nil {
+			return nil, err
+		}
+		if entry != nil && entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry != nil && entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := f.get(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		doc, err := decode(bytes.NewReader(entry.Body), entry.Charset)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{
+			OPML:         doc,
+			NotModified:  true,
+			ETag:         entry.ETag,
+			LastModified: entry.LastModified,
+		}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := decodeContent(resp)
+	if err != nil {
+		return nil, err
+	}
+	body = f.limit(body)
+
+	res := &Result{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	charset := contentCharset(resp)
+	cache := f.Cache != nil && (res.ETag != "" || res.LastModified != "")
+
+	var buf bytes.Buffer
+	if cache {
+		body = io.TeeReader(body, &buf)
+	}
+	if res.OPML, err = decode(body, charset); err != nil {
+		return nil, err
+	}
+	if cache {
+		// Read the rest of the body, e.g. trailing comments, so that the
+		// cached document is complete.
+		if _, err := io.Copy(ioutil.Discard, body); err != nil {
+			return nil, err
+		}
+		entry := &CacheEntry{
+			ETag:         res.ETag,
+			LastModified: res.LastModified,
+			Charset:      charset,
+			Body:         buf.Bytes(),
+		}
+		if err := f.Cache.Set(url, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// contentCharset returns the charset given by the Content-Type header of the
+// response, if any.
+func contentCharset(resp *http.Response) string {
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return params["charset"]
+}
+
+// decodeContent returns a reader decoding the body of the response according
+// to its Content-Encoding header.
+func decodeContent(resp *http.Response) (io.Reader, error) {
+	switch enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		// Deflate is supposed to be zlib-wrapped, but some servers send
+		// raw deflate data.
+		r := bufio.NewReader(resp.Body)
+		header, err := r.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		if (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			return zlib.NewReader(r)
+		}
+		return flate.NewReader(r), nil
+	default:
+		return nil, fmt.Errorf("opml: unsupported content encoding %q", enc)
+	}
+}
+
+// get sends a GET request with the given additional headers.
+func (f *Fetcher) get(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if f.Accept != "" {
+		req.Header.Set("Accept", f.Accept)
+	} else {
+		req.Header.Set("Accept", DefaultAccept)
+	}
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// limit returns a reader failing with ErrBodyTooLarge once more than the
+// maximum body size has been read from r.
+func (f *Fetcher) limit(r io.Reader) io.Reader {
+	max := f.MaxBodySize
+	if max == 0 {
+		max = DefaultMaxBodySize
+	}
+	if max < 0 {
+		return r
+	}
+	return &limitedReader{r: r, n: max}
+}
+
+type limitedReader struct {
+	r io.Reader
+	n int64 // remaining bytes
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	return n, err
+}
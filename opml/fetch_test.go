@@ -0,0 +1,116 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func feedsHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadFile("../testdata/feeds.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "text/x-opml")
+		w.Write(b)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	var header http.Header
+	handler := feedsHandler(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		handler(w, r)
+	}))
+	defer server.Close()
+
+	f := &Fetcher{Client: server.Client(), UserAgent: "opml-test/1.0"}
+	doc, err := f.Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDoc(t, doc)
+
+	if ua := header.Get("User-Agent"); ua != "opml-test/1.0" {
+		t.Errorf("Wrong User-Agent: expected 'opml-test/1.0', found '%s'", ua)
+	}
+	if accept := header.Get("Accept"); accept != DefaultAccept {
+		t.Errorf("Wrong Accept: expected '%s', found '%s'", DefaultAccept, accept)
+	}
+}
+
+func TestFetchStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>Not found</html>", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := new(Fetcher).Fetch(context.Background(), server.URL+"/feeds.opml")
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected an HTTPError, found %v", err)
+	}
+	if httpErr.StatusCode != http.StatusNotFound || httpErr.URL != server.URL+"/feeds.opml" {
+		t.Errorf("Wrong HTTPError: %+v", httpErr)
+	}
+}
+
+func TestFetchMaxBodySize(t *testing.T) {
+	server := httptest.NewServer(feedsHandler(t))
+	defer server.Close()
+
+	f := &Fetcher{MaxBodySize: 100}
+	if _, err := f.Fetch(context.Background(), server.URL); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Expected ErrBodyTooLarge, found %v", err)
+	}
+
+	f.MaxBodySize = -1
+	if _, err := f.Fetch(context.Background(), server.URL); err != nil {
+		t.Errorf("Unexpected error without size limit: %v", err)
+	}
+}
+
+func TestFetchContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := new(Fetcher).Fetch(ctx, server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, found %v", err)
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	r := &limitedReader{r: strings.NewReader("0123456789"), n: 10}
+	b, err := ioutil.ReadAll(r)
+	if err != nil || string(b) != "0123456789" {
+		t.Errorf("Unexpected result at the limit: '%s', %v", b, err)
+	}
+
+	r = &limitedReader{r: strings.NewReader("0123456789"), n: 9}
+	if _, err := io.Copy(ioutil.Discard, r); err != ErrBodyTooLarge {
+		t.Errorf("Expected ErrBodyTooLarge, found %v", err)
+	}
+}
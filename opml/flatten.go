@@ -0,0 +1,77 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+// Record is an outline of a flattened document.
+type Record struct {
+	// Folder lists the texts of the ancestors of the outline, from the
+	// top-level one.
+	Folder []string
+
+	// Ancestors holds the ancestors of the outline, from the top-level one,
+	// with all their attributes and no children.
+	Ancestors []Outline
+
+	// Outline is the outline, with all its attributes and no children.
+	Outline Outline
+}
+
+// Flatten returns the outlines of doc without children, e.g. feeds, in
+// document order. The outlines with children are folders, recorded in the
+// Folder and Ancestors fields of the records of their descendants.
+func Flatten(doc *OPML) []Record {
+	var records []Record
+	var folder []string
+	var ancestors []Outline
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		folder, ancestors = folder[:depth], ancestors[:depth]
+		if len(o.Outlines) > 0 {
+			a := cloneOutlines([]Outline{*o})[0]
+			a.Outlines = nil
+			folder, ancestors = append(folder, o.Text), append(ancestors, a)
+			return nil
+		}
+		r := Record{Outline: cloneOutlines([]Outline{*o})[0]}
+		if depth > 0 {
+			r.Folder = append([]string(nil), folder...)
+			r.Ancestors = cloneOutlines(ancestors)
+		}
+		records = append(records, r)
+		return nil
+	})
+	return records
+}
+
+// Unflatten rebuilds a body from records, as returned by Flatten. The
+// records are grouped in folders by folder path, in order of first
+// appearance. The folders are made of the ancestors of the record first
+// placed in them, where their texts match the folder path, and are outlines
+// with only a text otherwise.
+//
+// The records without folder are placed in the folder given by the first
+// slash-delimited path of their category attribute, if any, as defined by
+// OPML 2.0: a record with category "/Tech/Go,/Blogs" is placed in the Go
+// folder of the Tech folder.
+func Unflatten(records []Record) Body {
+	b := combineBuilder{doc: &OPML{}, folders: make(map[string][]int)}
+	for _, r := range records {
+		folder := r.Folder
+		if len(folder) == 0 {
+			folder = categoryFolder(r.Outline.Category)
+		}
+		ancestors := make([]*Outline, len(folder))
+		for i, text := range folder {
+			if i < len(r.Ancestors) && r.Ancestors[i].Text == text {
+				ancestors[i] = &r.Ancestors[i]
+			} else {
+				ancestors[i] = &Outline{Text: text}
+			}
+		}
+		o := cloneOutlines([]Outline{r.Outline})[0]
+		o.Outlines = nil
+		b.place(ancestors, o)
+	}
+	return b.doc.Body
+}
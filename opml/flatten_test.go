@@ -0,0 +1,89 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	doc := selectTestDoc()
+	records := Flatten(doc)
+
+	var found []string
+	for _, r := range records {
+		found = append(found, r.Outline.Text)
+		if len(r.Folder) > 0 {
+			found[len(found)-1] += " in " + r.Folder[len(r.Folder)-1]
+		}
+	}
+	expected := []string{"Go in Tech", "Rust in Lang", "Docs in Tech", "HN in News", "Empty"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Wrong records: expected %v, found %v", expected, found)
+	}
+	if !reflect.DeepEqual(records[1].Folder, []string{"Tech", "Lang"}) || records[1].Outline.Type != "atom" {
+		t.Errorf("Wrong record: %+v", records[1])
+	}
+	if records[4].Folder != nil {
+		t.Errorf("Unexpected folder: %v", records[4].Folder)
+	}
+
+	// Records do not share the outlines of doc.
+	records[0].Outline.Text = "Golang"
+	if doc.Body.Outlines[0].Outlines[0].Text != "Go" {
+		t.Error("Record shares the document outline")
+	}
+
+	body := Unflatten(Flatten(doc))
+	if !reflect.DeepEqual(body, doc.Body) {
+		t.Errorf("Wrong round trip: expected %s, found %s", texts(doc.Body.Outlines), texts(body.Outlines))
+	}
+
+	// Outlines with children keep their attributes and elements.
+	show := Outline{Text: "Show", Type: "rss", XMLURL: "https://example.com/show.xml",
+		Outlines: []Outline{{Text: "Episode 1"}, {Text: "Episode 2"}}}
+	tech := folder("Tech", show)
+	tech.Title = "Technology"
+	tech.SetAttr("custom", "value")
+	tech.Elements = []Element{{XMLName: xml.Name{Local: "note"}, Content: "Some note"}}
+	doc = &OPML{Body: Body{Outlines: []Outline{tech}}}
+
+	records = Flatten(doc)
+	if len(records) != 2 || records[0].Ancestors[1].XMLURL != show.XMLURL ||
+		records[0].Ancestors[0].Outlines != nil {
+		t.Errorf("Wrong records: %+v", records)
+	}
+	body = Unflatten(records)
+	if !reflect.DeepEqual(body, doc.Body) {
+		t.Errorf("Wrong round trip: expected %+v, found %+v", doc.Body, body)
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	records := []Record{
+		{Folder: []string{"Tech"}, Outline: Outline{Text: "Go", XMLURL: "https://go.dev/feed"}},
+		{Outline: Outline{Text: "HN", XMLURL: "https://news.ycombinator.com/rss"}},
+		{Folder: []string{"Tech", "Lang"}, Outline: Outline{Text: "Rust", Category: "/News/Ignored"}},
+		{Outline: Outline{Text: "Zig", Category: "Lang, /Tech/Lang/, /Blogs"}},
+		{Outline: Outline{Text: "LWN", Category: "/News"}},
+		{Folder: []string{"Tech"}, Outline: Outline{Text: "Docs", Outlines: []Outline{{Text: "Dropped"}}}},
+	}
+
+	body := Unflatten(records)
+	if tree := texts(body.Outlines); tree != "Tech(Go Lang(Rust Zig) Docs) HN News(LWN)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if o := body.Outlines[0]; !reflect.DeepEqual(o, Outline{Text: "Tech", Outlines: o.Outlines}) {
+		t.Errorf("Wrong folder: %+v", o)
+	}
+	if body.Outlines[0].Outlines[1].Outlines[1].Category != "Lang, /Tech/Lang/, /Blogs" {
+		t.Errorf("Category not kept: %+v", body.Outlines[0].Outlines[1].Outlines[1])
+	}
+	if len(records[5].Outline.Outlines) != 1 {
+		t.Error("Record modified")
+	}
+}
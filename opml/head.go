@@ -0,0 +1,269 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateFormat is the RFC 822 layout used to write dates, as in the examples of
+// the specification. Dates are written in UTC.
+const DateFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// FieldError describes a malformed value of a head element or an outline
+// attribute.
+type FieldError struct {
+	Field string // name of the element or attribute, e.g. "dateCreated"
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("opml: invalid %s %q: %v", e.Field, e.Value, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Window holds the position of the window displaying the document, in
+// pixels.
+type Window struct {
+	Top, Left, Bottom, Right int
+}
+
+// Created returns the date of creation of the document, or the zero time if
+// it is not set.
+func (h *Head) Created() (time.Time, error) {
+	return headDate("dateCreated", h.DateCreated)
+}
+
+// SetCreated sets the date of creation of the document. The zero time unsets
+// it.
+func (h *Head) SetCreated(t time.Time) {
+	h.DateCreated = FormatDate(t)
+}
+
+// Modified returns the date of last modification of the document, or the
+// zero time if it is not set.
+func (h *Head) Modified() (time.Time, error) {
+	return headDate("dateModified", h.DateModified)
+}
+
+// SetModified sets the date of last modification of the document. The zero
+// time unsets it.
+func (h *Head) SetModified(t time.Time) {
+	h.DateModified = FormatDate(t)
+}
+
+// Expansion returns the line numbers of the expanded outlines, as defined by
+// the expansionState element.
+func (h *Head) Expansion() ([]int, error) {
+	if strings.TrimSpace(h.ExpansionState) == "" {
+		return nil, nil
+	}
+
+	var lines []int
+	for _, f := range strings.Split(h.ExpansionState, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err == nil && n < 1 {
+			err = errors.New("line numbers start at 1")
+		}
+		if err != nil {
+			return nil, &FieldError{"expansionState", h.ExpansionState, err}
+		}
+		lines = append(lines, n)
+	}
+	return lines, nil
+}
+
+// SetExpansion sets the line numbers of the expanded outlines.
+func (h *Head) SetExpansion(lines []int) {
+	s := make([]string, len(lines))
+	for i, n := range lines {
+		s[i] = strconv.Itoa(n)
+	}
+	h.ExpansionState = strings.Join(s, ", ")
+}
+
+// VertScroll returns the line number of the top visible line, or 0 if it is
+// not set.
+func (h *Head) VertScroll() (int, error) {
+	return headInt("vertScrollState", h.VertScrollState)
+}
+
+// SetVertScroll sets the line number of the top visible line. 0 unsets it.
+func (h *Head) SetVertScroll(n int) {
+	h.VertScrollState = formatInt(n)
+}
+
+// Window returns the position of the window. Unset edges are 0.
+func (h *Head) Window() (Window, error) {
+	var w Window
+	var err error
+	fields := []struct {
+		name  string
+		value string
+		dst   *int
+	}{
+		{"windowTop", h.WindowTop, &w.Top},
+		{"windowLeft", h.WindowLeft, &w.Left},
+		{"windowBottom", h.WindowBottom, &w.Bottom},
+		{"windowRight", h.WindowRight, &w.Right},
+	}
+	for _, f := range fields {
+		if *f.dst, err = headInt(f.name, f.value); err != nil {
+			return Window{}, err
+		}
+	}
+	return w, nil
+}
+
+// SetWindow sets the position of the window. Edges equal to 0 are unset.
+func (h *Head) SetWindow(w Window) {
+	h.WindowTop = formatInt(w.Top)
+	h.WindowLeft = formatInt(w.Left)
+	h.WindowBottom = formatInt(w.Bottom)
+	h.WindowRight = formatInt(w.Right)
+}
+
+func headDate(field, value string) (time.Time, error) {
+	if strings.TrimSpace(value) == "" {
+		return time.Time{}, nil
+	}
+	t, err := ParseDate(value)
+	if err != nil {
+		return time.Time{}, &FieldError{field, value, err}
+	}
+	return t, nil
+}
+
+func headInt(field, value string) (int, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err == nil && n < 0 {
+		err = errors.New("negative number")
+	}
+	if err != nil {
+		return 0, &FieldError{field, value, err}
+	}
+	return n, nil
+}
+
+func formatInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// FormatDate formats t in RFC 822 format, in UTC. It returns the empty string
+// for the zero time.
+func FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(DateFormat)
+}
+
+// ParseDate parses a date in RFC 822 format, as required by the
+// specification, with two- or four-digit years. It also accepts the
+// non-conformant variants commonly found in the wild: missing or full weekday
+// and month names, other zone formats, RFC 3339 and ISO 8601 dates.
+func ParseDate(s string) (time.Time, error) {
+	t, _, err := parseDate(s)
+	return t, err
+}
+
+// rfc822Layouts holds the layouts allowed by RFC 822, with two-digit years,
+// and their RFC 1123 variants with four-digit years.
+var rfc822Layouts = combineLayouts(
+	[]string{"Mon, ", ""},
+	[]string{"2 Jan "},
+	[]string{"2006 ", "06 "},
+	[]string{"15:04:05 ", "15:04 "},
+	[]string{"MST", "-0700"},
+)
+
+// lenientLayouts holds the non-conformant layouts accepted by ParseDate.
+var lenientLayouts = append(combineLayouts(
+	[]string{"Monday, ", "Mon ", "Mon, ", ""},
+	[]string{"2 January ", "2 Jan "},
+	[]string{"2006 "},
+	[]string{"15:04:05 ", "15:04 "},
+	[]string{"MST", "-0700", "-07:00", "MST-0700"},
+),
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.UnixDate,
+	time.ANSIC,
+	time.RubyDate,
+)
+
+func combineLayouts(parts ...[]string) []string {
+	layouts := []string{""}
+	for _, p := range parts {
+		var next []string
+		for _, prefix := range layouts {
+			for _, s := range p {
+				next = append(next, prefix+s)
+			}
+		}
+		layouts = next
+	}
+	return layouts
+}
+
+// zoneOffsets holds the offsets of the time zones named by RFC 822, which
+// time.Parse does not know unless they are used by the local time zone.
+var zoneOffsets = map[string]int{
+	"UT": 0, "GMT": 0, "Z": 0,
+	"EST": -5, "EDT": -4,
+	"CST": -6, "CDT": -5,
+	"MST": -7, "MDT": -6,
+	"PST": -8, "PDT": -7,
+}
+
+// parseDate parses a date and reports whether it is in RFC 822 format.
+func parseDate(s string) (time.Time, bool, error) {
+	s = strings.Join(strings.Fields(s), " ")
+
+	// time.Parse does not recognize the one and two letter zones of RFC 822.
+	if strings.HasSuffix(s, " UT") || strings.HasSuffix(s, " Z") {
+		s = s[:strings.LastIndex(s, " ")] + " GMT"
+	}
+
+	for i, layouts := range [][]string{rfc822Layouts, lenientLayouts} {
+		for _, layout := range layouts {
+			t, err := time.Parse(layout, s)
+			if err == nil {
+				return fixZone(t), i == 0, nil
+			}
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("unrecognized date format %q", s)
+}
+
+func fixZone(t time.Time) time.Time {
+	name, offset := t.Zone()
+	hours, ok := zoneOffsets[strings.ToUpper(name)]
+	if !ok || offset == hours*3600 {
+		return t
+	}
+	zone := time.FixedZone(name, hours*3600)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond(), zone)
+}
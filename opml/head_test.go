@@ -0,0 +1,160 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	expected := time.Date(2005, time.October, 31, 19, 23, 0, 0, time.UTC)
+
+	tests := []struct {
+		value  string
+		strict bool
+	}{
+		{"Mon, 31 Oct 2005 19:23:00 GMT", true},
+		{"31 Oct 2005 19:23:00 GMT", true},
+		{"Mon, 31 Oct 05 19:23 GMT", true},
+		{"Mon, 31 Oct 2005 14:23:00 EST", true},
+		{"Mon, 31 Oct 2005 12:23:00 PDT", true},
+		{"Mon, 31 Oct 2005 21:23:00 +0200", true},
+		{"Mon, 31 Oct 2005 19:23:00 UT", true},
+		{"Mon,  31 Oct 2005 19:23:00  GMT", true},
+		{"Monday, 31 October 2005 19:23:00 GMT", false},
+		{"Mon 31 Oct 2005 19:23:00 GMT", false},
+		{"Mon, 31 Oct 2005 21:23:00 +02:00", false},
+		{"2005-10-31T19:23:00Z", false},
+		{"2005-10-31T20:23:00+01:00", false},
+		{"2005-10-31 19:23:00", false},
+	}
+
+	for _, test := range tests {
+		date, strict, err := parseDate(test.value)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %v", test.value, err)
+			continue
+		}
+		if !date.Equal(expected) {
+			t.Errorf("Wrong date for '%s': expected %v, found %v", test.value, expected, date)
+		}
+		if strict != test.strict {
+			t.Errorf("Wrong conformance for '%s': expected %v, found %v",
+				test.value, test.strict, strict)
+		}
+	}
+
+	if _, err := ParseDate("yesterday"); err == nil {
+		t.Error("Expected failure!")
+	}
+}
+
+func TestHeadDates(t *testing.T) {
+	var h Head
+
+	created, err := h.Created()
+	if err != nil || !created.IsZero() {
+		t.Errorf("Expected zero time for an unset date, found %v, %v", created, err)
+	}
+
+	date := time.Date(2008, time.July, 6, 23, 2, 0, 0, time.FixedZone("CEST", 2*3600))
+	h.SetCreated(date)
+	h.SetModified(date)
+	if h.DateCreated != "Sun, 06 Jul 2008 21:02:00 GMT" {
+		t.Errorf("Wrong dateCreated: found '%s'", h.DateCreated)
+	}
+
+	doc := OPML{Version: "2.0", Head: h}
+	xml, err := doc.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(xml, "<dateModified>Sun, 06 Jul 2008 21:02:00 GMT</dateModified>") {
+		t.Errorf("Date not serialized in RFC 822 format:\n%s", xml)
+	}
+
+	modified, err := h.Modified()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !modified.Equal(date) {
+		t.Errorf("Wrong date of modification: expected %v, found %v", date, modified)
+	}
+
+	h.DateCreated = "06/07/2008"
+	_, err = h.Created()
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "dateCreated" {
+		t.Errorf("Expected a FieldError for dateCreated, found %v", err)
+	}
+}
+
+func TestHeadExpansion(t *testing.T) {
+	h := Head{ExpansionState: "1, 6,13"}
+
+	lines, err := h.Expansion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lines, []int{1, 6, 13}) {
+		t.Errorf("Wrong expansion state: found %v", lines)
+	}
+
+	h.SetExpansion([]int{2, 4})
+	if h.ExpansionState != "2, 4" {
+		t.Errorf("Wrong expansion state: expected '2, 4', found '%s'", h.ExpansionState)
+	}
+
+	for _, value := range []string{"1, a", "0", "1,,2"} {
+		h.ExpansionState = value
+		if _, err := h.Expansion(); err == nil {
+			t.Errorf("Expected failure for '%s'", value)
+		}
+	}
+}
+
+func TestHeadWindow(t *testing.T) {
+	h := Head{
+		VertScrollState: "3",
+		WindowTop:       "61",
+		WindowLeft:      "304",
+		WindowBottom:    " 562",
+	}
+
+	n, err := h.VertScroll()
+	if err != nil || n != 3 {
+		t.Errorf("Wrong vertScrollState: expected 3, found %d, %v", n, err)
+	}
+
+	w, err := h.Window()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != (Window{Top: 61, Left: 304, Bottom: 562}) {
+		t.Errorf("Wrong window: found %+v", w)
+	}
+
+	h.SetWindow(Window{Top: 1, Left: 2, Bottom: 3, Right: 4})
+	h.SetVertScroll(0)
+	if h.WindowTop != "1" || h.WindowRight != "4" || h.VertScrollState != "" {
+		t.Errorf("Wrong window fields: %+v", h)
+	}
+
+	h.WindowRight = "wide"
+	_, err = h.Window()
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "windowRight" {
+		t.Errorf("Expected a FieldError for windowRight, found %v", err)
+	}
+
+	h.VertScrollState = "-3"
+	if _, err := h.VertScroll(); !errors.As(err, &fieldErr) || fieldErr.Field != "vertScrollState" {
+		t.Errorf("Expected a FieldError for vertScrollState, found %v", err)
+	}
+}
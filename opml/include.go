@@ -0,0 +1,248 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxIncludeDepth is the maximum nesting depth of the includes
+// expanded by an Includer whose MaxDepth is 0.
+const DefaultMaxIncludeDepth = 8
+
+// ErrIncludeCycle is returned, wrapped in an IncludeError, when a document
+// includes itself, directly or not.
+var ErrIncludeCycle = errors.New("opml: include cycle")
+
+// ErrIncludeDepth is returned, wrapped in an IncludeError, when includes are
+// nested deeper than allowed by an Includer.
+var ErrIncludeDepth = errors.New("opml: include depth limit exceeded")
+
+// ErrIncludeChildren is returned, wrapped in an IncludeError, when an include
+// outline already has children, which expanding it would discard.
+var ErrIncludeChildren = errors.New("opml: include outline has children")
+
+// Resolver retrieves the documents included by include outlines.
+type Resolver interface {
+	// Resolve returns the document at the URL. The document is owned by
+	// the caller.
+	Resolve(ctx context.Context, url string) (*OPML, error)
+}
+
+// Resolve implements the Resolver interface, retrieving documents over
+// HTTP.
+func (f *Fetcher) Resolve(ctx context.Context, url string) (*OPML, error) {
+	return f.Fetch(ctx, url)
+}
+
+// FileResolver is a Resolver reading documents from the file system. URLs
+// are file paths or file: URLs.
+type FileResolver struct {
+	// Dir is the directory of relative paths. If empty, they are relative
+	// to the current directory.
+	Dir string
+}
+
+// Resolve implements the Resolver interface.
+func (r FileResolver) Resolve(ctx context.Context, rawurl string) (*OPML, error) {
+	path := rawurl
+	if strings.HasPrefix(rawurl, "file:") {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+		path = u.Path
+	}
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.Dir, path)
+	}
+	return NewOPMLFromFile(path)
+}
+
+// MapResolver is a Resolver returning copies of the documents it holds, by
+// URL. Unknown URLs yield an error wrapping os.ErrNotExist.
+type MapResolver map[string]*OPML
+
+// Resolve implements the Resolver interface.
+func (r MapResolver) Resolve(ctx context.Context, url string) (*OPML, error) {
+	doc, ok := r[url]
+	if !ok {
+		return nil, fmt.Errorf("opml: %s: %w", url, os.ErrNotExist)
+	}
+	return doc.Clone(), nil
+}
+
+// IncludeError describes an include outline that could not be expanded.
+type IncludeError struct {
+	URL  string // URL of the included document, resolved against the base
+	Path []int  // index path of the include outline in the expanded document
+	Err  error
+}
+
+func (e *IncludeError) Error() string {
+	return fmt.Sprintf("opml: include %s at %v: %v", e.URL, e.Path, e.Err)
+}
+
+func (e *IncludeError) Unwrap() error {
+	return e.Err
+}
+
+// An Includer expands the include outlines of documents, i.e. the outlines
+// of type "include" with a url attribute, replacing them with the body of
+// the documents they point to. The zero value retrieves documents with a
+// zero Fetcher.
+type Includer struct {
+	// Resolver retrieves the included documents. If nil, a zero Fetcher is
+	// used.
+	Resolver Resolver
+
+	// Base is the URL of the expanded document, against which the relative
+	// URLs of its includes are resolved. The URLs of the includes of an
+	// included document are resolved against the URL of that document.
+	Base string
+
+	// MaxDepth is the maximum nesting depth of includes: an include of an
+	// included document has depth 2. If 0, DefaultMaxIncludeDepth is used.
+	MaxDepth int
+
+	// KeepIncludes keeps the include outlines, with the outlines of the
+	// included documents as children, instead of replacing them. The
+	// expanded document can then be restored with CollapseIncludes.
+	KeepIncludes bool
+}
+
+// ExpandIncludes expands the include outlines of doc with an Includer using
+// the resolver, and returns the includes that could not be expanded.
+func ExpandIncludes(ctx context.Context, doc *OPML, r Resolver) []*IncludeError {
+	in := Includer{Resolver: r}
+	return in.Expand(ctx, doc)
+}
+
+// Expand expands the include outlines of doc, recursively, and returns the
+// includes that could not be expanded, in document order. These are left
+// unchanged. Include outlines that already have children, e.g. in a document
+// expanded with KeepIncludes, are not expanded: the document can be collapsed
+// with CollapseIncludes first.
+func (in *Includer) Expand(ctx context.Context, doc *OPML) []*IncludeError {
+	e := &includeExpander{Includer: in, resolver: in.Resolver, maxDepth: in.MaxDepth}
+	if e.resolver == nil {
+		e.resolver = new(Fetcher)
+	}
+	if e.maxDepth == 0 {
+		e.maxDepth = DefaultMaxIncludeDepth
+	}
+	var chain []string
+	if in.Base != "" {
+		chain = append(chain, in.Base)
+	}
+	e.expand(ctx, &doc.Body.Outlines, nil, 0, in.Base, chain)
+	return e.errs
+}
+
+type includeExpander struct {
+	*Includer
+	resolver Resolver
+	maxDepth int
+	errs     []*IncludeError
+}
+
+// expand expands the includes of a list of outlines, whose paths start with
+// prefix and whose indexes start at offset. The chain lists the URLs of the
+// documents including the list.
+func (e *includeExpander) expand(ctx context.Context, list *[]Outline, prefix []int, offset int, base string, chain []string) {
+	for i := 0; i < len(*list); i++ {
+		o := &(*list)[i]
+		path := append(copyPath(prefix), offset+i)
+		if !isInclude(o) {
+			e.expand(ctx, &o.Outlines, path, 0, base, chain)
+			continue
+		}
+
+		u := resolveReference(base, o.URL)
+		if len(o.Outlines) > 0 {
+			e.errs = append(e.errs, &IncludeError{u, path, ErrIncludeChildren})
+			continue
+		}
+		children, err := e.resolve(ctx, u, chain)
+		if err != nil {
+			e.errs = append(e.errs, &IncludeError{u, path, err})
+			continue
+		}
+		chain := append(chain[:len(chain):len(chain)], u)
+		if e.KeepIncludes {
+			o.Outlines = children
+			e.expand(ctx, &o.Outlines, path, 0, u, chain)
+			continue
+		}
+		e.expand(ctx, &children, prefix, offset+i, u, chain)
+		*list = append((*list)[:i], append(children, (*list)[i+1:]...)...)
+		i += len(children) - 1
+	}
+}
+
+// resolve returns the outlines of the document at the URL.
+func (e *includeExpander) resolve(ctx context.Context, u string, chain []string) ([]Outline, error) {
+	for _, c := range chain {
+		if c == u {
+			return nil, ErrIncludeCycle
+		}
+	}
+	depth := len(chain)
+	if e.Base != "" {
+		depth--
+	}
+	if depth >= e.maxDepth {
+		return nil, ErrIncludeDepth
+	}
+	doc, err := e.resolver.Resolve(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Body.Outlines, nil
+}
+
+func isInclude(o *Outline) bool {
+	return strings.EqualFold(o.Type, "include") && o.URL != ""
+}
+
+// resolveReference resolves a URL against a base URL or file path.
+func resolveReference(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil || r.IsAbs() {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil || !b.IsAbs() {
+		// File path.
+		if filepath.IsAbs(ref) {
+			return ref
+		}
+		return filepath.Join(filepath.Dir(base), ref)
+	}
+	return b.ResolveReference(r).String()
+}
+
+// CollapseIncludes removes the children of the include outlines of doc, as
+// kept by an Includer with KeepIncludes set, restoring the document as it
+// was before expansion.
+func CollapseIncludes(doc *OPML) {
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		if isInclude(o) {
+			o.Outlines = nil
+			return SkipChildren
+		}
+		return nil
+	})
+}
@@ -0,0 +1,226 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func include(text, url string) Outline {
+	return Outline{Text: text, Type: "include", URL: url}
+}
+
+func includeTestDocs() (*OPML, MapResolver) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		folder("Tech", include("TechList", "tech.opml")),
+		include("NewsList", "news.opml"),
+		include("LoopList", "/loop.opml"),
+		include("Missing", "missing.opml"),
+		{Text: "Docs", URL: "https://go.dev/doc"},
+	}}}
+	r := MapResolver{
+		"https://example.com/lists/tech.opml": {Body: Body{Outlines: []Outline{{Text: "Go"}, {Text: "Rust"}}}},
+		"https://example.com/lists/news.opml": {Body: Body{Outlines: []Outline{
+			{Text: "HN"},
+			include("Main", "main.opml"),
+		}}},
+		"https://example.com/loop.opml": {Body: Body{Outlines: []Outline{include("Loop", "https://example.com/loop.opml")}}},
+	}
+	return doc, r
+}
+
+func TestExpandIncludes(t *testing.T) {
+	doc, r := includeTestDocs()
+	in := Includer{Resolver: r, Base: "https://example.com/lists/main.opml"}
+	errs := in.Expand(context.Background(), doc)
+
+	if tree := texts(doc.Body.Outlines); tree != "Tech(Go Rust) HN Main Loop Missing Docs" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+
+	expected := []struct {
+		url  string
+		path []int
+		err  error
+	}{
+		{"https://example.com/lists/main.opml", []int{2}, ErrIncludeCycle},
+		{"https://example.com/loop.opml", []int{3}, ErrIncludeCycle},
+		{"https://example.com/lists/missing.opml", []int{4}, os.ErrNotExist},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Wrong errors: %v", errs)
+	}
+	for i, e := range expected {
+		if errs[i].URL != e.url || !reflect.DeepEqual(errs[i].Path, e.path) || !errors.Is(errs[i], e.err) {
+			t.Errorf("Wrong error: expected %v at %v (%v), found %v", e.url, e.path, e.err, errs[i])
+		}
+	}
+
+	// The resolver documents are not modified.
+	if len(r["https://example.com/loop.opml"].Body.Outlines[0].Outlines) != 0 {
+		t.Error("Resolver document modified")
+	}
+}
+
+func TestExpandIncludesKeep(t *testing.T) {
+	doc, r := includeTestDocs()
+	original := doc.Clone()
+	in := Includer{Resolver: r, Base: "https://example.com/lists/main.opml", KeepIncludes: true}
+	errs := in.Expand(context.Background(), doc)
+
+	if tree := texts(doc.Body.Outlines); tree != "Tech(TechList(Go Rust)) NewsList(HN Main) LoopList(Loop) Missing Docs" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if len(errs) != 3 || !reflect.DeepEqual(errs[0].Path, []int{1, 1}) || !reflect.DeepEqual(errs[1].Path, []int{2, 0}) {
+		t.Errorf("Wrong errors: %v", errs)
+	}
+
+	CollapseIncludes(doc)
+	if !reflect.DeepEqual(doc, original) {
+		t.Errorf("Wrong collapsed tree: found '%s'", texts(doc.Body.Outlines))
+	}
+}
+
+func TestExpandIncludesChildren(t *testing.T) {
+	r := MapResolver{"a.opml": {Body: Body{Outlines: []Outline{{Text: "a"}}}}}
+	withChildren := include("A", "a.opml")
+	withChildren.Outlines = []Outline{{Text: "Mine"}}
+
+	for _, keep := range []bool{false, true} {
+		doc := &OPML{Body: Body{Outlines: []Outline{withChildren, include("B", "a.opml")}}}
+		in := Includer{Resolver: r, KeepIncludes: keep}
+		errs := in.Expand(context.Background(), doc)
+
+		expected := "A(Mine) a"
+		if keep {
+			expected = "A(Mine) B(a)"
+		}
+		if tree := texts(doc.Body.Outlines); tree != expected {
+			t.Errorf("keep %v: wrong tree: expected '%s', found '%s'", keep, expected, tree)
+		}
+		if len(errs) != 1 || !reflect.DeepEqual(errs[0].Path, []int{0}) || !errors.Is(errs[0], ErrIncludeChildren) {
+			t.Errorf("keep %v: wrong errors: %v", keep, errs)
+		}
+	}
+
+	// A document expanded with KeepIncludes is not expanded again.
+	doc := &OPML{Body: Body{Outlines: []Outline{include("B", "a.opml")}}}
+	in := Includer{Resolver: r, KeepIncludes: true}
+	in.Expand(context.Background(), doc)
+	if errs := in.Expand(context.Background(), doc); len(errs) != 1 || !errors.Is(errs[0], ErrIncludeChildren) {
+		t.Errorf("Wrong errors: %v", errs)
+	}
+	CollapseIncludes(doc)
+	if errs := in.Expand(context.Background(), doc); len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "B(a)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+}
+
+func TestExpandIncludesDepth(t *testing.T) {
+	r := MapResolver{
+		"a.opml": {Body: Body{Outlines: []Outline{include("B", "b.opml")}}},
+		"b.opml": {Body: Body{Outlines: []Outline{{Text: "b"}, include("C", "c.opml")}}},
+		"c.opml": {Body: Body{Outlines: []Outline{{Text: "c"}}}},
+	}
+	doc := &OPML{Body: Body{Outlines: []Outline{include("A", "a.opml")}}}
+	in := Includer{Resolver: r, MaxDepth: 2}
+	errs := in.Expand(context.Background(), doc)
+
+	if tree := texts(doc.Body.Outlines); tree != "b C" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if len(errs) != 1 || errs[0].URL != "c.opml" || !reflect.DeepEqual(errs[0].Path, []int{1}) || !errors.Is(errs[0], ErrIncludeDepth) {
+		t.Errorf("Wrong errors: %v", errs)
+	}
+}
+
+func TestFileResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"main.opml":      `<opml version="2.0"><body><outline text="Feeds" type="include" url="sub/feeds.opml"/></body></opml>`,
+		"sub/feeds.opml": `<opml version="2.0"><body><outline text="Go"/><outline text="More" type="include" url="more.opml"/></body></opml>`,
+		"sub/more.opml":  `<opml version="2.0"><body><outline text="Rust"/></body></opml>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	doc, err := NewOPMLFromFile(filepath.Join(dir, "main.opml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := Includer{Resolver: FileResolver{}, Base: filepath.Join(dir, "main.opml")}
+	if errs := in.Expand(context.Background(), doc); errs != nil {
+		t.Fatal(errs)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "Go Rust" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+
+	// Without base, relative paths are resolved against the directory of
+	// the resolver, then against the path of the including document.
+	doc, _ = NewOPMLFromFile(filepath.Join(dir, "main.opml"))
+	if errs := ExpandIncludes(context.Background(), doc, FileResolver{Dir: dir}); errs != nil {
+		t.Fatal(errs)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "Go Rust" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if errs := ExpandIncludes(context.Background(), &OPML{Body: Body{Outlines: []Outline{include("Main", "main.opml")}}}, FileResolver{}); len(errs) != 1 || !errors.Is(errs[0], os.ErrNotExist) {
+		t.Errorf("Wrong errors: %v", errs)
+	}
+}
+
+func TestFetcherResolver(t *testing.T) {
+	files := map[string]string{
+		"/lists/main.opml":  `<opml version="2.0"><body><outline text="Feeds" type="include" url="/lists/feeds.opml"/></body></opml>`,
+		"/lists/feeds.opml": `<opml version="2.0"><body><outline text="Go"/><outline text="Loop" type="INCLUDE" url="main.opml"/></body></opml>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	f := &Fetcher{Client: server.Client()}
+	doc, err := f.Fetch(context.Background(), server.URL+"/lists/main.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := Includer{Resolver: f, Base: server.URL + "/lists/main.opml"}
+	errs := in.Expand(context.Background(), doc)
+	if tree := texts(doc.Body.Outlines); tree != "Go Loop" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if len(errs) != 1 || errs[0].URL != server.URL+"/lists/main.opml" || !errors.Is(errs[0], ErrIncludeCycle) {
+		t.Errorf("Wrong errors: %v", errs)
+	}
+}
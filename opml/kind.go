@@ -0,0 +1,163 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidKind is returned, wrapped, when an outline lacks the attributes
+// required by its type.
+var ErrInvalidKind = errors.New("opml: invalid outline for its type")
+
+// Kind is a typed view of an outline, as returned by Registry.Kind. Its
+// dynamic type depends on the type attribute of the outline, e.g. Feed for
+// "rss", so that outlines can be handled with a type switch.
+type Kind interface {
+	// Base returns the outline viewed.
+	Base() *Outline
+}
+
+// Feed is the view of outlines of type "rss".
+type Feed struct{ *Outline }
+
+// Link is the view of outlines of type "link", whose url attribute points to
+// an HTML page.
+type Link struct{ *Outline }
+
+// Include is the view of outlines of type "include", whose url attribute
+// points to an OPML document to include. See Includer.
+type Include struct{ *Outline }
+
+// Text is the view of outlines without type, or of a type unknown to the
+// registry.
+type Text struct{ *Outline }
+
+// Base implements the Kind interface.
+func (f Feed) Base() *Outline { return f.Outline }
+
+// Base implements the Kind interface.
+func (l Link) Base() *Outline { return l.Outline }
+
+// Base implements the Kind interface.
+func (i Include) Base() *Outline { return i.Outline }
+
+// Base implements the Kind interface.
+func (t Text) Base() *Outline { return t.Outline }
+
+// KindType describes an outline type registered in a Registry.
+type KindType struct {
+	// Name is the value of the type attribute, compared case-insensitively.
+	Name string
+
+	// Validate checks the attributes of an outline of the type. If nil,
+	// all outlines are valid.
+	Validate func(o *Outline) error
+
+	// Decode returns the view of a valid outline of the type. It may
+	// decode attributes into fields of the view.
+	Decode func(o *Outline) (Kind, error)
+}
+
+// Registry maps outline types to their views. The zero value is an empty
+// registry ready to use, viewing all outlines as Text. Its methods may be
+// called concurrently.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]KindType
+}
+
+// DefaultRegistry is the registry used by Outline.Kind. It holds the types
+// defined by OPML 2.0: "rss", "link" and "include".
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns a registry holding the types defined by OPML 2.0:
+// "rss", "link" and "include".
+func NewRegistry() *Registry {
+	r := new(Registry)
+	r.Register(KindType{
+		Name:     "rss",
+		Validate: requireAttr("rss", "xmlUrl"),
+		Decode:   func(o *Outline) (Kind, error) { return Feed{o}, nil },
+	})
+	r.Register(KindType{
+		Name:     "link",
+		Validate: requireAttr("link", "url"),
+		Decode:   func(o *Outline) (Kind, error) { return Link{o}, nil },
+	})
+	r.Register(KindType{
+		Name:     "include",
+		Validate: requireAttr("include", "url"),
+		Decode:   func(o *Outline) (Kind, error) { return Include{o}, nil },
+	})
+	return r
+}
+
+// requireAttr returns a validation function checking that the attribute is
+// set.
+func requireAttr(typ, name string) func(o *Outline) error {
+	return func(o *Outline) error {
+		if o.Attr(name) == "" {
+			return fmt.Errorf("%w: outline of type %s has no %s attribute", ErrInvalidKind, typ, name)
+		}
+		return nil
+	}
+}
+
+// Register adds a type to the registry, replacing the one of the same name.
+// It panics if the name is empty or Decode is nil.
+func (r *Registry) Register(t KindType) {
+	if t.Name == "" || t.Decode == nil {
+		panic("opml: invalid KindType")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.types == nil {
+		r.types = make(map[string]KindType)
+	}
+	r.types[strings.ToLower(t.Name)] = t
+}
+
+// Lookup returns the registered type of the name, compared
+// case-insensitively.
+func (r *Registry) Lookup(name string) (KindType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[strings.ToLower(name)]
+	return t, ok
+}
+
+// Validate checks the attributes of the outline against its registered
+// type. Outlines of an unknown type are valid.
+func (r *Registry) Validate(o *Outline) error {
+	t, ok := r.Lookup(o.Type)
+	if !ok || t.Validate == nil {
+		return nil
+	}
+	return t.Validate(o)
+}
+
+// Kind returns the view of the outline given by its registered type, or
+// Text if the type is unknown. The outline is validated first.
+func (r *Registry) Kind(o *Outline) (Kind, error) {
+	t, ok := r.Lookup(o.Type)
+	if !ok {
+		return Text{o}, nil
+	}
+	if t.Validate != nil {
+		if err := t.Validate(o); err != nil {
+			return nil, err
+		}
+	}
+	return t.Decode(o)
+}
+
+// Kind returns the view of the outline given by DefaultRegistry.
+func (o *Outline) Kind() (Kind, error) {
+	return DefaultRegistry.Kind(o)
+}
@@ -0,0 +1,112 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestKind(t *testing.T) {
+	tests := []struct {
+		outline  Outline
+		expected string
+	}{
+		{Outline{Text: "Go", Type: "rss", XMLURL: "https://go.dev/feed"}, "Feed"},
+		{Outline{Text: "Go", Type: "RSS", XMLURL: "https://go.dev/feed"}, "Feed"},
+		{Outline{Text: "Docs", Type: "link", URL: "https://go.dev/doc"}, "Link"},
+		{Outline{Text: "More", Type: "include", URL: "more.opml"}, "Include"},
+		{Outline{Text: "Notes"}, "Text"},
+		{Outline{Text: "Custom", Type: "custom"}, "Text"},
+		{Outline{Text: "Go", Type: "rss"}, ""},
+		{Outline{Text: "More", Type: "include"}, ""},
+	}
+
+	for _, test := range tests {
+		o := test.outline
+		k, err := o.Kind()
+		var found string
+		switch k := k.(type) {
+		case Feed:
+			found = "Feed"
+			if k.XMLURL != o.XMLURL {
+				t.Errorf("%s: wrong xmlUrl %q", o.Text, k.XMLURL)
+			}
+		case Link:
+			found = "Link"
+		case Include:
+			found = "Include"
+		case Text:
+			found = "Text"
+		}
+		if found != test.expected {
+			t.Errorf("%s (%s): expected %s, found %s", o.Text, o.Type, test.expected, found)
+		}
+		if found == "" && !errors.Is(err, ErrInvalidKind) {
+			t.Errorf("%s (%s): expected ErrInvalidKind, found %v", o.Text, o.Type, err)
+		}
+		if k != nil && k.Base() != &o {
+			t.Errorf("%s: view of another outline", o.Text)
+		}
+	}
+}
+
+// episode is a custom outline type, decoding an attribute.
+type episode struct {
+	*Outline
+	Number int
+}
+
+func (e episode) Base() *Outline { return e.Outline }
+
+func TestRegistry(t *testing.T) {
+	r := new(Registry)
+	if k, err := r.Kind(&Outline{Type: "rss"}); err != nil || k == nil {
+		t.Errorf("Unexpected result for empty registry: %v, %v", k, err)
+	} else if _, ok := k.(Text); !ok {
+		t.Errorf("Expected Text, found %T", k)
+	}
+
+	errMissing := errors.New("missing episode number")
+	r.Register(KindType{
+		Name: "Episode",
+		Validate: func(o *Outline) error {
+			if o.Attr("podcast:episode") == "" {
+				return errMissing
+			}
+			return nil
+		},
+		Decode: func(o *Outline) (Kind, error) {
+			n, err := strconv.Atoi(o.Attr("podcast:episode"))
+			return episode{o, n}, err
+		},
+	})
+
+	o := &Outline{Text: "Pilot", Type: "episode"}
+	if err := r.Validate(o); err != errMissing {
+		t.Errorf("Expected validation error, found %v", err)
+	}
+	if _, err := r.Kind(o); err != errMissing {
+		t.Errorf("Expected validation error, found %v", err)
+	}
+
+	o.SetAttr("podcast:episode", "x")
+	if _, err := r.Kind(o); err == nil {
+		t.Error("Expected decoding error")
+	}
+	o.SetAttr("podcast:episode", "1")
+	k, err := r.Kind(o)
+	if e, ok := k.(episode); err != nil || !ok || e.Number != 1 || e.Base() != o {
+		t.Errorf("Wrong view: %#v, %v", k, err)
+	}
+
+	if _, ok := r.Lookup("EPISODE"); !ok {
+		t.Error("Registered type not found")
+	}
+	if _, ok := DefaultRegistry.Lookup("episode"); ok {
+		t.Error("Type registered in the default registry")
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// FixKind identifies the kind of defect repaired by NewOPMLLenient.
+type FixKind string
+
+// Kinds of repaired defects.
+const (
+	FixEntity      FixKind = "entity"        // HTML entity replaced by a character reference
+	FixAmpersand   FixKind = "ampersand"     // unescaped ampersand escaped
+	FixControlChar FixKind = "control-char"  // control character removed
+	FixUnclosed    FixKind = "unclosed"      // unclosed element closed
+	FixStrayEndTag FixKind = "stray-end-tag" // end tag without start tag removed
+)
+
+// Fix describes a defect repaired by NewOPMLLenient.
+type Fix struct {
+	Kind    FixKind
+	Line    int // line of the defect in the input, starting at 1
+	Message string
+}
+
+func (f Fix) String() string {
+	return fmt.Sprintf("line %d: %s", f.Line, f.Message)
+}
+
+// NewOPMLLenient creates a new OPML structure from a slice of bytes, like
+// NewOPML, repairing the defects commonly found in documents exported by
+// real-world applications:
+//
+//   - HTML entities such as &nbsp; are replaced by character references;
+//   - ampersands that do not start a reference are escaped;
+//   - control characters, which are not allowed in XML, are removed;
+//   - unclosed elements are closed when an enclosing element ends;
+//   - end tags without matching start tags are removed.
+//
+// Attribute values without quotes and attributes without values are also
+// accepted. The repairs are returned along with the document.
+func NewOPMLLenient(b []byte) (*OPML, []Fix, error) {
+	var fixes []Fix
+	if !bytes.HasPrefix(b, []byte{0xfe, 0xff}) && !bytes.HasPrefix(b, []byte{0xff, 0xfe}) {
+		// The repairs below only apply to ASCII-compatible encodings.
+		b = repairText(b, &fixes)
+	}
+
+	b, charset, err := repairStructure(b, &fixes)
+	if err != nil {
+		return nil, fixes, err
+	}
+
+	doc, err := decode(bytes.NewReader(b), "UTF-8")
+	if err != nil {
+		return nil, fixes, err
+	}
+	if !isUTF8(charset) {
+		doc.Encoding = charset
+	}
+	return doc, fixes, nil
+}
+
+// repairText fixes references and control characters.
+func repairText(b []byte, fixes *[]Fix) []byte {
+	var out bytes.Buffer
+	out.Grow(len(b))
+	line := 1
+
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == '\n':
+			line++
+		case c < 0x20 && c != '\t' && c != '\r':
+			*fixes = append(*fixes, Fix{FixControlChar, line,
+				fmt.Sprintf("removed control character %#02x", c)})
+			continue
+		case c == '<':
+			// Comments, CDATA sections and processing instructions are
+			// copied unchanged.
+			if n := section(b[i:]); n > 0 {
+				line += bytes.Count(b[i:i+n], []byte("\n"))
+				out.Write(b[i : i+n])
+				i += n - 1
+				continue
+			}
+		case c == '&':
+			ref, name := reference(b[i:])
+			switch {
+			case ref != "":
+				out.WriteString(ref)
+				i += len(ref) - 1
+				continue
+			case name != "":
+				r, ok := xml.HTMLEntity[name]
+				if !ok {
+					break
+				}
+				*fixes = append(*fixes, Fix{FixEntity, line,
+					fmt.Sprintf("replaced entity &%s; by a character reference", name)})
+				for _, c := range r {
+					out.WriteString("&#" + strconv.Itoa(int(c)) + ";")
+				}
+				i += len(name) + 1
+				continue
+			}
+			*fixes = append(*fixes, Fix{FixAmpersand, line, "escaped unescaped ampersand"})
+			out.WriteString("&amp;")
+			continue
+		}
+		out.WriteByte(c)
+	}
+
+	return out.Bytes()
+}
+
+// section returns the length of the comment, CDATA section or processing
+// instruction at the start of b, or 0 if there is none.
+func section(b []byte) int {
+	for _, delim := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}} {
+		if !bytes.HasPrefix(b, []byte(delim[0])) {
+			continue
+		}
+		end := bytes.Index(b[len(delim[0]):], []byte(delim[1]))
+		if end < 0 {
+			return len(b)
+		}
+		return len(delim[0]) + end + len(delim[1])
+	}
+	return 0
+}
+
+// reference returns the valid XML reference at the start of b, or the name of
+// the entity reference unknown to XML at the start of b.
+func reference(b []byte) (ref, name string) {
+	end := bytes.IndexByte(b, ';')
+	if end < 2 || end > 40 {
+		return "", ""
+	}
+	s := string(b[1:end])
+	switch {
+	case s == "amp", s == "lt", s == "gt", s == "quot", s == "apos":
+		return string(b[:end+1]), ""
+	case len(s) > 2 && (s[:2] == "#x" || s[:2] == "#X"):
+		if _, err := strconv.ParseUint(s[2:], 16, 32); err == nil {
+			return string(b[:end+1]), ""
+		}
+	case s[0] == '#':
+		if _, err := strconv.ParseUint(s[1:], 10, 32); err == nil {
+			return string(b[:end+1]), ""
+		}
+	default:
+		for _, c := range s {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				return "", ""
+			}
+		}
+		return "", s
+	}
+	return "", ""
+}
+
+// repairStructure closes unclosed elements and removes stray end tags. The
+// document is returned in UTF-8, along with its original encoding.
+func repairStructure(b []byte, fixes *[]Fix) ([]byte, string, error) {
+	var charset string
+	d, lines := newXMLDecoder(bytes.NewReader(b), &charset)
+	d.Strict = false
+
+	var out bytes.Buffer
+	e := xml.NewEncoder(&out)
+
+	type open struct {
+		name xml.Name
+		line int
+	}
+	var stack []open
+	line := func() int {
+		line, _ := lines.position(d.InputOffset())
+		return line
+	}
+	closeTop := func(reason string) {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		*fixes = append(*fixes, Fix{FixUnclosed, line(),
+			fmt.Sprintf("closed <%s> opened at line %d %s", top.name.Local, top.line, reason)})
+		e.EncodeToken(xml.EndElement{Name: top.name})
+	}
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, charset, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			t.Name = rawName(t.Name)
+			for i := range t.Attr {
+				t.Attr[i].Name = rawName(t.Attr[i].Name)
+			}
+			stack = append(stack, open{t.Name, line()})
+			tok = t
+		case xml.EndElement:
+			t.Name = rawName(t.Name)
+			matched := -1
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == t.Name {
+					matched = i
+					break
+				}
+			}
+			if matched < 0 {
+				*fixes = append(*fixes, Fix{FixStrayEndTag, line(),
+					fmt.Sprintf("removed end tag </%s> without start tag", t.Name.Local)})
+				continue
+			}
+			for len(stack)-1 > matched {
+				closeTop(fmt.Sprintf("before </%s>", t.Name.Local))
+			}
+			stack = stack[:matched]
+			tok = t
+		case xml.ProcInst:
+			if t.Target == "xml" {
+				// The output is in UTF-8.
+				continue
+			}
+		}
+
+		if err := e.EncodeToken(xml.CopyToken(tok)); err != nil {
+			return nil, charset, err
+		}
+	}
+
+	for len(stack) > 0 {
+		closeTop("at end of document")
+	}
+	if err := e.Flush(); err != nil {
+		return nil, charset, err
+	}
+	return out.Bytes(), charset, nil
+}
+
+// rawName turns a name returned by RawToken, whose Space holds the prefix,
+// into a name written unchanged by an xml.Encoder.
+func rawName(n xml.Name) xml.Name {
+	if n.Space == "" {
+		return n
+	}
+	return xml.Name{Local: n.Space + ":" + n.Local}
+}
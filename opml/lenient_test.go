@@ -0,0 +1,125 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+const brokenOPML = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+	"<opml version=\"1.0\">\n" +
+	"<head><title>Caf&eacute;&nbsp;Feeds</title></head>\n" +
+	"<body>\n" +
+	"<outline text=\"Tom & Jerry\" xmlUrl=\"http://example.com/rss?a=1&b=2&amp;c=3\">\n" +
+	"<outline text=\"Bell\x07\" type=rss xmlUrl=\"http://example.com/bell\">\n" +
+	"</body>\n" +
+	"<!-- & is fine here --></outline>\n" +
+	"</opml>"
+
+func TestNewOPMLLenient(t *testing.T) {
+	if _, err := NewOPML([]byte(brokenOPML)); err == nil {
+		t.Fatal("Expected the strict parser to fail")
+	}
+
+	doc, fixes, err := NewOPMLLenient([]byte(brokenOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Head.Title != "Café Feeds" {
+		t.Errorf("Wrong title: found %q", doc.Head.Title)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "Tom & Jerry(Bell)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	o := doc.Body.Outlines[0]
+	if o.XMLURL != "http://example.com/rss?a=1&b=2&c=3" {
+		t.Errorf("Wrong xmlUrl: found '%s'", o.XMLURL)
+	}
+	if o.Outlines[0].Type != "rss" {
+		t.Errorf("Wrong unquoted type: found '%s'", o.Outlines[0].Type)
+	}
+
+	expected := []Fix{
+		{FixEntity, 3, "replaced entity &eacute; by a character reference"},
+		{FixEntity, 3, "replaced entity &nbsp; by a character reference"},
+		{FixAmpersand, 5, "escaped unescaped ampersand"},
+		{FixAmpersand, 5, "escaped unescaped ampersand"},
+		{FixControlChar, 6, "removed control character 0x07"},
+		{FixUnclosed, 7, "closed <outline> opened at line 6 before </body>"},
+		{FixUnclosed, 7, "closed <outline> opened at line 5 before </body>"},
+		{FixStrayEndTag, 8, "removed end tag </outline> without start tag"},
+	}
+	if !reflect.DeepEqual(fixes, expected) {
+		t.Errorf("Wrong fixes: expected\n%v\nfound\n%v", expected, fixes)
+	}
+}
+
+func TestNewOPMLLenientUnclosedAtEOF(t *testing.T) {
+	doc, fixes, err := NewOPMLLenient([]byte(`<opml version="2.0"><body><outline text="a">`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "a" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if len(fixes) != 3 {
+		t.Errorf("Wrong number of fixes: expected 3, found %v", fixes)
+	}
+}
+
+func TestNewOPMLLenientValid(t *testing.T) {
+	doc, fixes, err := NewOPMLLenient([]byte(extendedOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 0 {
+		t.Errorf("Unexpected fixes: %v", fixes)
+	}
+
+	opml, err := doc.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opml != extendedOPML {
+		t.Errorf("Invalid generated OPML: expected\n\n%s\n\nfound\n\n%s",
+			extendedOPML, opml)
+	}
+}
+
+func TestNewOPMLLenientCharset(t *testing.T) {
+	doc, _, err := NewOPMLLenient(charsetDoc("ISO-8859-1", "Caf\xe9 & Co", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Head.Title != "Café & Co" || doc.Encoding != "ISO-8859-1" {
+		t.Errorf("Wrong document: title '%s', encoding '%s'", doc.Head.Title, doc.Encoding)
+	}
+}
+
+func TestNewOPMLLenientLines(t *testing.T) {
+	doc := "<?xml version=\"1.0\" encoding=\"%s\"?>\n<opml version=\"2.0\">\n<body>\n" +
+		"<outline text=\"a\">\n</body>\n</opml>"
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes(
+		[]byte(strings.Replace(doc, "%s", "UTF-16", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range [][]byte{[]byte(strings.Replace(doc, "%s", "UTF-8", 1)), utf16} {
+		_, fixes, err := NewOPMLLenient(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Fix{{FixUnclosed, 5, "closed <outline> opened at line 4 before </body>"}}
+		if !reflect.DeepEqual(fixes, expected) {
+			t.Errorf("Wrong fixes: expected %v, found %v", expected, fixes)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Attributes used by the Markdown and plain text conversions. They are not
+// defined by OPML 2.0 but follow the conventions of common outliners.
+const (
+	// NoteAttr holds the note of an outline: the text following it in its
+	// item.
+	NoteAttr = "_note"
+
+	// CompleteAttr holds the state of task outlines: "true" for completed
+	// tasks, "false" for pending ones.
+	CompleteAttr = "complete"
+)
+
+// tabWidth is the number of columns a tab stands for in indentation.
+const tabWidth = 4
+
+var (
+	headingRE  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	bulletRE   = regexp.MustCompile(`^(?:[-*+]|\d+[.)])(?:\s+|$)`)
+	taskRE     = regexp.MustCompile(`^\[([ xX])\](?:\s+|$)`)
+	linkRE     = regexp.MustCompile(`^\[((?:[^\]\\]|\\.)*)\]\(\s*([^)\s]+)(?:\s+"[^"]*")?\s*\)$`)
+	autolinkRE = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]*:[^>\s]+)>$`)
+
+	// markupRE matches the starts of lines read as markup.
+	markupRE = regexp.MustCompile(`^(?:[\\\[#<]|(?:[-*+]|\d+[.)])(?:\s|$))`)
+
+	// linkEscaper escapes the text of links, read back by linkUnescaper.
+	linkEscaper   = strings.NewReplacer(`\`, `\\`, `]`, `\]`)
+	linkUnescaper = strings.NewReplacer(`\\`, `\`, `\]`, `]`)
+)
+
+// ParseText parses an outline written as indented plain text: each non-blank
+// line is an outline, nested in the previous less indented one. Indentation
+// is made of tabs or any number of spaces, and only compared between lines.
+func ParseText(r io.Reader) (*OPML, error) {
+	return parseIndented(r, false)
+}
+
+// ParseMarkdown parses an outline written in Markdown, as nested bullet lists
+// and headings. Each heading or list item is an outline, nested in the
+// previous heading of lower level or less indented list item. Indentation is
+// made of tabs or any number of spaces.
+//
+// Items made of a single link, e.g. "[Go](https://go.dev)" or
+// "<https://go.dev>", become outlines of type "link". Task items, starting
+// with "[ ]" or "[x]", have their CompleteAttr attribute set. The lines
+// following an item, other than headings and items, are kept in its NoteAttr
+// attribute. Markup at the start of text and notes can be escaped with a
+// backslash, e.g. "\[x] literally" or "\1. literally".
+func ParseMarkdown(r io.Reader) (*OPML, error) {
+	return parseIndented(r, true)
+}
+
+// textNode is an outline being parsed, with its children.
+type textNode struct {
+	outline  Outline
+	children []*textNode
+	indent   int // indentation of list items, -1 for headings and the root
+	heading  int // level of headings, 0 for list items and the root
+}
+
+func parseIndented(r io.Reader, markdown bool) (*OPML, error) {
+	root := &textNode{indent: -1}
+	stack := []*textNode{root}
+	var last *textNode
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		text := strings.TrimLeft(line, " \t")
+		if text == "" {
+			continue
+		}
+		indent := indentWidth(line[:len(line)-len(text)])
+
+		n := &textNode{indent: indent}
+		switch {
+		case !markdown:
+			n.outline.Text = text
+		case headingRE.MatchString(text):
+			m := headingRE.FindStringSubmatch(text)
+			n.indent = -1
+			n.heading = len(m[1])
+			n.outline = markdownItem(m[2])
+		case bulletRE.MatchString(text):
+			n.outline = markdownItem(text[len(bulletRE.FindString(text)):])
+		case last != nil:
+			note := last.outline.Attr(NoteAttr)
+			if note != "" {
+				note += "\n"
+			}
+			last.outline.SetAttr(NoteAttr, note+unescapeMarkdown(text))
+			continue
+		default:
+			n.outline = markdownItem(text)
+		}
+
+		// Find the parent of the node.
+		for len(stack) > 1 {
+			top := stack[len(stack)-1]
+			if n.heading > 0 {
+				if top.heading > 0 && top.heading < n.heading {
+					break
+				}
+			} else if top.indent < n.indent {
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, n)
+		stack = append(stack, n)
+		last = n
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return &OPML{Version: "2.0", Body: Body{Outlines: root.outlines()}}, nil
+}
+
+// outlines returns the children of the node as outlines.
+func (n *textNode) outlines() []Outline {
+	if len(n.children) == 0 {
+		return nil
+	}
+	outlines := make([]Outline, len(n.children))
+	for i, c := range n.children {
+		outlines[i] = c.outline
+		outlines[i].Outlines = c.outlines()
+	}
+	return outlines
+}
+
+// indentWidth returns the width of indentation, in columns.
+func indentWidth(s string) int {
+	w := 0
+	for _, c := range s {
+		if c == '\t' {
+			w += tabWidth - w%tabWidth
+		} else {
+			w++
+		}
+	}
+	return w
+}
+
+// markdownItem returns the outline of the text of a list item or heading.
+func markdownItem(text string) Outline {
+	var o Outline
+	if m := taskRE.FindStringSubmatch(text); m != nil {
+		o.SetAttr(CompleteAttr, boolString(m[1] != " "))
+		text = text[len(m[0]):]
+	}
+	if m := linkRE.FindStringSubmatch(text); m != nil {
+		o.Text, o.Type, o.URL = linkUnescaper.Replace(m[1]), "link", m[2]
+	} else if m := autolinkRE.FindStringSubmatch(text); m != nil {
+		o.Text, o.Type, o.URL = m[1], "link", m[1]
+	} else {
+		o.Text = unescapeMarkdown(text)
+	}
+	return o
+}
+
+// escapeMarkdown escapes the markup at the start of s, after indentation, so
+// that it is read as text at the start of a line.
+func escapeMarkdown(s string) string {
+	text := strings.TrimLeft(s, " \t")
+	if markupRE.MatchString(text) {
+		return s[:len(s)-len(text)] + `\` + text
+	}
+	return s
+}
+
+// unescapeMarkdown removes the escaping of escapeMarkdown.
+func unescapeMarkdown(s string) string {
+	if strings.HasPrefix(s, `\`) && markupRE.MatchString(s[1:]) {
+		return s[1:]
+	}
+	return s
+}
+
+// markdownHeading returns the line of a heading of the given level. Text
+// ending with "#" is followed by a closing sequence, so that it is not read as
+// one.
+func markdownHeading(level int, text string) string {
+	if strings.HasSuffix(text, "#") {
+		text += " #"
+	}
+	return strings.Repeat("#", level) + " " + text + "\n"
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// WriteText writes the outlines of doc as indented plain text, one line per
+// outline, indented with tabs.
+func WriteText(w io.Writer, doc *OPML) error {
+	var b strings.Builder
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		b.WriteString(strings.Repeat("\t", depth) + o.Text + "\n")
+		return nil
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// A MarkdownWriter writes documents in Markdown, as nested bullet lists. The
+// zero value writes lists indented with two spaces, without headings.
+type MarkdownWriter struct {
+	// Indent is the indentation of nested items. If empty, two spaces are
+	// used.
+	Indent string
+
+	// Bullet is the marker of list items. If empty, "-" is used.
+	Bullet string
+
+	// Headings is the number of levels of outlines written as headings
+	// instead of list items: with 1, top-level outlines are headings.
+	Headings int
+
+	// Title writes the title of the document as a first level heading. The
+	// levels of the other headings are then shifted by one.
+	Title bool
+}
+
+// WriteMarkdown writes doc in Markdown with a zero MarkdownWriter.
+func WriteMarkdown(w io.Writer, doc *OPML) error {
+	return new(MarkdownWriter).Write(w, doc)
+}
+
+// Write writes doc in Markdown. Outlines with a url, htmlUrl or xmlUrl
+// attribute are written as links, tasks with their state, and notes below
+// their outline. Text and note lines starting with markup, and "]" and "\" in
+// the text of links, are escaped, as parsed by ParseMarkdown.
+func (m *MarkdownWriter) Write(w io.Writer, doc *OPML) error {
+	indent, bullet := m.Indent, m.Bullet
+	if indent == "" {
+		indent = "  "
+	}
+	if bullet == "" {
+		bullet = "-"
+	}
+	shift := 0
+	if m.Title && doc.Head.Title != "" {
+		shift = 1
+	}
+
+	var b strings.Builder
+	if shift > 0 {
+		b.WriteString(markdownHeading(1, doc.Head.Title))
+	}
+	afterHeading := shift > 0
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		text := escapeMarkdown(o.Text)
+		if href := outlineHref(o); href != "" {
+			text = "[" + linkEscaper.Replace(o.Text) + "](" + href + ")"
+		}
+		switch o.Attr(CompleteAttr) {
+		case "true":
+			text = "[x] " + text
+		case "false":
+			text = "[ ] " + text
+		}
+		var note string
+		if n := o.Attr(NoteAttr); n != "" {
+			lines := strings.Split(n, "\n")
+			for i, line := range lines {
+				lines[i] = escapeMarkdown(line)
+			}
+			note = strings.Join(lines, "\n")
+		}
+
+		if depth < m.Headings {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(markdownHeading(depth+1+shift, text))
+			if note != "" {
+				b.WriteString("\n" + note + "\n")
+			}
+			afterHeading = true
+			return nil
+		}
+
+		if afterHeading {
+			b.WriteString("\n")
+			afterHeading = false
+		}
+		prefix := strings.Repeat(indent, depth-m.Headings)
+		b.WriteString(prefix + bullet + " " + text + "\n")
+		if note != "" {
+			noteIndent := prefix + strings.Repeat(" ", len(bullet)+1)
+			for _, line := range strings.Split(note, "\n") {
+				b.WriteString(noteIndent + line + "\n")
+			}
+		}
+		return nil
+	})
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// outlineHref returns the URL an outline points to: its url, htmlUrl or
+// xmlUrl attribute.
+func outlineHref(o *Outline) string {
+	switch {
+	case o.URL != "":
+		return o.URL
+	case o.HTMLURL != "":
+		return o.HTMLURL
+	}
+	return o.XMLURL
+}
@@ -0,0 +1,257 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"Tech\n\tGo\n\tLang\n\t\tRust\nNews\n", "Tech(Go Lang(Rust)) News"},
+		{"Tech\n   Go\n   Lang\n      Rust\n\n  \nNews", "Tech(Go Lang(Rust)) News"},
+		{"Tech\n    Go\n\tLang\n        Rust\r\n  News\n", "Tech(Go Lang(Rust) News)"},
+		{"  Tech\n    Go\nNews\n", "Tech(Go) News"},
+		{"- Tech\n", "- Tech"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		doc, err := ParseText(strings.NewReader(test.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree := texts(doc.Body.Outlines); tree != test.expected {
+			t.Errorf("%q: expected '%s', found '%s'", test.text, test.expected, tree)
+		}
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	md := `# Reading list
+
+Things to read.
+
+## Tech
+- [Go](https://go.dev "The Go site")
+  Official site
+  and blog
+    * <https://blog.rust-lang.org>
+	+ [x] Zig
+1. [ ] Nim
+
+## News
+- HN
+`
+	doc, err := ParseMarkdown(strings.NewReader(md))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "Reading list(Tech(Go(https://blog.rust-lang.org Zig) Nim) News(HN))" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+
+	root := doc.Body.Outlines[0]
+	if note := root.Attr(NoteAttr); note != "Things to read." {
+		t.Errorf("Wrong note: %q", note)
+	}
+	tech := root.Outlines[0]
+	if o := tech.Outlines[0]; o.Type != "link" || o.URL != "https://go.dev" || o.Attr(NoteAttr) != "Official site\nand blog" {
+		t.Errorf("Wrong link: %+v", o)
+	}
+	if o := tech.Outlines[0].Outlines[0]; o.Type != "link" || o.URL != "https://blog.rust-lang.org" {
+		t.Errorf("Wrong link: %+v", o)
+	}
+	if o := tech.Outlines[0].Outlines[1]; o.Attr(CompleteAttr) != "true" || o.Type != "" {
+		t.Errorf("Wrong task: %+v", o)
+	}
+	if o := tech.Outlines[1]; o.Attr(CompleteAttr) != "false" {
+		t.Errorf("Wrong task: %+v", o)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		folder("Tech", Outline{Text: "Go"}, folder("Lang", Outline{Text: "Rust"})),
+		{Text: "News"},
+	}}}
+
+	var b strings.Builder
+	if err := WriteText(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Tech\n\tGo\n\tLang\n\t\tRust\nNews\n"
+	if b.String() != expected {
+		t.Errorf("Wrong text: expected %q, found %q", expected, b.String())
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	doc := &OPML{Head: Head{Title: "Reading list"}, Body: Body{Outlines: []Outline{
+		folder("Tech",
+			Outline{Text: "Go", Type: "rss", XMLURL: "https://go.dev/feed", HTMLURL: "https://go.dev/blog"},
+			Outline{Text: "Zig"}),
+		{Text: "News", Outlines: []Outline{{Text: "HN"}}},
+	}}}
+	doc.Body.Outlines[0].SetAttr(NoteAttr, "Programming")
+	doc.Body.Outlines[0].Outlines[0].SetAttr(NoteAttr, "Official blog\nWeekly")
+	doc.Body.Outlines[0].Outlines[1].SetAttr(CompleteAttr, "false")
+
+	tests := []struct {
+		w        MarkdownWriter
+		expected string
+	}{
+		{MarkdownWriter{}, `- Tech
+  Programming
+  - [Go](https://go.dev/blog)
+    Official blog
+    Weekly
+  - [ ] Zig
+- News
+  - HN
+`},
+		{MarkdownWriter{Indent: "\t", Bullet: "*", Headings: 1, Title: true}, `# Reading list
+
+## Tech
+
+Programming
+
+* [Go](https://go.dev/blog)
+  Official blog
+  Weekly
+* [ ] Zig
+
+## News
+
+* HN
+`},
+	}
+
+	for _, test := range tests {
+		var b strings.Builder
+		if err := test.w.Write(&b, doc); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != test.expected {
+			t.Errorf("%+v: expected\n%s\nfound\n%s", test.w, test.expected, b.String())
+		}
+
+		parsed, err := ParseMarkdown(strings.NewReader(b.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outlines := parsed.Body.Outlines
+		if test.w.Title {
+			outlines = outlines[0].Outlines
+		}
+		if tree := texts(outlines); tree != "Tech(Go Zig) News(HN)" {
+			t.Errorf("%+v: wrong tree: found '%s'", test.w, tree)
+		}
+		if note := outlines[0].Outlines[0].Attr(NoteAttr); note != "Official blog\nWeekly" {
+			t.Errorf("%+v: wrong note: %q", test.w, note)
+		}
+	}
+
+	var b strings.Builder
+	if err := WriteMarkdown(&b, doc); err != nil || !strings.HasPrefix(b.String(), "- Tech\n") {
+		t.Errorf("Wrong Markdown: %q, %v", b.String(), err)
+	}
+}
+
+func TestMarkdownEscaping(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		folder("# Tech", Outline{Text: "[x] literally"}, Outline{Text: "- dash"}),
+		{Text: `\back`},
+		{Text: "1. first"},
+		{Text: "<https://go.dev>"},
+		{Text: "[ ] task"},
+	}}}
+	doc.Body.Outlines[0].Outlines[0].SetAttr(NoteAttr, "- sub\n  * star\n# title\n2) two\nplain")
+	doc.Body.Outlines[4].SetAttr(CompleteAttr, "false")
+
+	for _, w := range []MarkdownWriter{{}, {Headings: 1}} {
+		var b strings.Builder
+		if err := w.Write(&b, doc); err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseMarkdown(strings.NewReader(b.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tree := texts(parsed.Body.Outlines); tree != texts(doc.Body.Outlines) {
+			t.Errorf("%+v: wrong tree: found '%s' in\n%s", w, tree, b.String())
+			continue
+		}
+		o := parsed.Body.Outlines[0].Outlines[0]
+		if o.Attr(CompleteAttr) != "" || o.Attr(NoteAttr) != "- sub\n* star\n# title\n2) two\nplain" {
+			t.Errorf("%+v: wrong outline: %+v", w, o)
+		}
+		if o := parsed.Body.Outlines[3]; o.Type != "" {
+			t.Errorf("%+v: wrong outline: %+v", w, o)
+		}
+		if o := parsed.Body.Outlines[4]; o.Text != "[ ] task" || o.Attr(CompleteAttr) != "false" {
+			t.Errorf("%+v: wrong task: %+v", w, o)
+		}
+	}
+}
+
+func TestMarkdownLinkEscaping(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		{Text: "a]b", Type: "link", URL: "http://x/"},
+		{Text: `back\slash\]`, Type: "link", URL: "http://y/"},
+	}}}
+
+	var b strings.Builder
+	if err := WriteMarkdown(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := "- [a\\]b](http://x/)\n- [back\\\\slash\\\\\\]](http://y/)\n"
+	if b.String() != expected {
+		t.Errorf("Wrong Markdown: expected\n%s\nfound\n%s", expected, b.String())
+	}
+
+	parsed, err := ParseMarkdown(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed.Body, doc.Body) {
+		t.Errorf("Wrong round trip: expected %+v, found %+v", doc.Body, parsed.Body)
+	}
+}
+
+func TestMarkdownHeadingEscaping(t *testing.T) {
+	doc := &OPML{Head: Head{Title: "C#"}, Body: Body{Outlines: []Outline{
+		folder("C#", Outline{Text: "F#"}),
+		{Text: "C #"},
+		{Text: "#"},
+		{Text: "Go"},
+	}}}
+
+	var b strings.Builder
+	w := MarkdownWriter{Headings: 1, Title: true}
+	if err := w.Write(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "# C# #\n\n## C# #\n") {
+		t.Errorf("Wrong Markdown:\n%s", b.String())
+	}
+
+	parsed, err := ParseMarkdown(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := parsed.Body.Outlines; len(o) != 1 || o[0].Text != "C#" {
+		t.Fatalf("Wrong title in\n%s", b.String())
+	}
+	if tree := texts(parsed.Body.Outlines[0].Outlines); tree != texts(doc.Body.Outlines) {
+		t.Errorf("Wrong tree: found '%s' in\n%s", tree, b.String())
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ConflictKind is the kind of a merge Conflict.
+type ConflictKind string
+
+// Kinds of merge conflicts.
+const (
+	ConflictEdit   ConflictKind = "edit"   // value changed differently on both sides
+	ConflictDelete ConflictKind = "delete" // outline deleted on one side, edited on the other
+	ConflictMove   ConflictKind = "move"   // outline moved to different parents
+)
+
+// Conflict describes a conflicting change found by Merge3, along with the
+// resolution applied.
+type Conflict struct {
+	Kind ConflictKind
+
+	// Key identifies the outline, as defined by Compare. It is empty for
+	// conflicting head elements.
+	Key string
+
+	// Name is the name of the attribute or head element of edit conflicts.
+	Name string
+
+	// Base, Ours and Theirs are the conflicting values of edit conflicts,
+	// or the keys of the parents of move conflicts.
+	Base, Ours, Theirs string
+
+	// Path is the index path of the outline in the merged document.
+	Path []int
+}
+
+func (c Conflict) String() string {
+	switch c.Kind {
+	case ConflictEdit:
+		if c.Key == "" {
+			return fmt.Sprintf("edit conflict on %s: ours %q, theirs %q", c.Name, c.Ours, c.Theirs)
+		}
+		return fmt.Sprintf("edit conflict on %s of %s: ours %q, theirs %q", c.Name, c.Key, c.Ours, c.Theirs)
+	case ConflictMove:
+		return fmt.Sprintf("move conflict on %s: ours under %q, theirs under %q", c.Key, c.Ours, c.Theirs)
+	}
+	return fmt.Sprintf("delete conflict on %s", c.Key)
+}
+
+// Merge3 merges the changes made to base in ours and in theirs, and returns
+// the merged document along with the conflicts found. Outlines are matched
+// by key, as by Compare, which uses feed URLs where present.
+//
+// Changes made on one side only are applied. Conflicts are resolved as
+// follows:
+//
+//   - a value changed differently on both sides keeps our value;
+//   - an outline deleted on one side and edited, moved or given new children
+//     on the other side is kept;
+//   - an outline moved to different parents on both sides is moved to our
+//     parent.
+//
+// Siblings are ordered as in ours, except for outlines added or moved only
+// in theirs, which are placed after their previous sibling in theirs. The
+// expansion state is taken from the side that changed it.
+func Merge3(base, ours, theirs *OPML) (*OPML, []Conflict) {
+	m := &merger{
+		base:   newMergeSide(nil, base),
+		ours:   newMergeSide(base, ours),
+		theirs: newMergeSide(base, theirs),
+		parent: make(map[string]string),
+		kept:   make(map[string]bool),
+	}
+
+	merged := ours.Clone()
+	merged.Version = m.mergeValue("", "version", base.Version, ours.Version, theirs.Version)
+	merged.Head = m.mergeHead(&base.Head, &ours.Head, &theirs.Head)
+	m.keep()
+	m.reparentOrphans()
+	merged.Body.Outlines = m.build("")
+
+	expansion := m.ours
+	if ours.Head.ExpansionState == base.Head.ExpansionState {
+		expansion = m.theirs
+	}
+	m.setExpansion(merged, expansion)
+
+	_, index := diffIndex(merged)
+	for i := range m.conflicts {
+		if e := index[m.conflicts[i].Key]; e != nil && m.conflicts[i].Key != "" {
+			m.conflicts[i].Path = e.path
+		}
+	}
+	return merged, m.conflicts
+}
+
+// mergeSide holds a document being merged, indexed by key.
+type mergeSide struct {
+	doc      *OPML
+	entries  []*diffEntry
+	index    map[string]*diffEntry
+	children map[string][]string // keys of the children of each parent, in order
+	moved    map[string]bool     // keys of the outlines moved from base
+	edited   map[string]bool     // keys of the outlines modified from base
+}
+
+func newMergeSide(base, doc *OPML) *mergeSide {
+	s := &mergeSide{
+		doc:      doc,
+		children: make(map[string][]string),
+		moved:    make(map[string]bool),
+		edited:   make(map[string]bool),
+	}
+	s.entries, s.index = diffIndex(doc)
+	for _, e := range s.entries {
+		s.children[e.parent] = append(s.children[e.parent], e.key)
+	}
+	if base == nil {
+		return s
+	}
+
+	_, baseIndex := diffIndex(base)
+	for _, c := range Compare(base, doc).Outlines {
+		switch c.Kind {
+		case Moved:
+			s.moved[c.Key] = true
+		case Modified:
+			s.edited[c.Key] = true
+		}
+	}
+	for _, e := range s.entries {
+		if b := baseIndex[e.key]; b != nil && !reflect.DeepEqual(b.outline.Elements, e.outline.Elements) {
+			s.edited[e.key] = true
+		}
+	}
+	return s
+}
+
+type merger struct {
+	base, ours, theirs *mergeSide
+	keys               []string          // keys of all the outlines, ours first
+	parent             map[string]string // merged parent of each kept outline
+	kept               map[string]bool
+	conflicts          []Conflict
+}
+
+// keep selects the outlines of the merged document and their parents.
+func (m *merger) keep() {
+	seen := make(map[string]bool)
+	for _, s := range []*mergeSide{m.ours, m.theirs} {
+		for _, e := range s.entries {
+			if !seen[e.key] {
+				seen[e.key] = true
+				m.keys = append(m.keys, e.key)
+			}
+		}
+	}
+
+	for _, k := range m.keys {
+		b, o, t := m.base.index[k], m.ours.index[k], m.theirs.index[k]
+		switch {
+		case o != nil && t != nil:
+			m.kept[k] = true
+			m.parent[k] = m.mergeParent(k, b, o, t)
+		case b == nil:
+			// Added on one side.
+			m.kept[k] = true
+			m.parent[k] = m.side(k).index[k].parent
+		default:
+			// Deleted on one side.
+			s := m.side(k)
+			if s.edited[k] || s.moved[k] {
+				m.kept[k] = true
+				m.parent[k] = s.index[k].parent
+				m.conflicts = append(m.conflicts, Conflict{Kind: ConflictDelete, Key: k})
+			}
+		}
+	}
+}
+
+// side returns the side holding the outline, ours if both do.
+func (m *merger) side(key string) *mergeSide {
+	if m.ours.index[key] != nil {
+		return m.ours
+	}
+	return m.theirs
+}
+
+func (m *merger) mergeParent(key string, b, o, t *diffEntry) string {
+	switch {
+	case o.parent == t.parent:
+		return o.parent
+	case b != nil && o.parent == b.parent:
+		return t.parent
+	case b != nil && t.parent == b.parent:
+		return o.parent
+	}
+	c := Conflict{Kind: ConflictMove, Key: key, Ours: o.parent, Theirs: t.parent}
+	if b != nil {
+		c.Base = b.parent
+	}
+	m.conflicts = append(m.conflicts, c)
+	return o.parent
+}
+
+// reparentOrphans keeps the parents of the kept outlines, and breaks the
+// cycles that moves on both sides may create by using our parents.
+func (m *merger) reparentOrphans() {
+	for _, k := range m.keys {
+		if !m.kept[k] {
+			continue
+		}
+		for p := m.parent[k]; p != "" && !m.kept[p]; p = m.parent[p] {
+			// The parent was deleted on one side while the other side
+			// added or kept children.
+			m.kept[p] = true
+			m.parent[p] = m.side(p).index[p].parent
+			m.conflicts = append(m.conflicts, Conflict{Kind: ConflictDelete, Key: p})
+		}
+	}
+
+	for {
+		reachable := make(map[string]bool)
+		var visit func(parent string)
+		visit = func(parent string) {
+			for _, k := range m.childKeys(parent) {
+				if !reachable[k] {
+					reachable[k] = true
+					visit(k)
+				}
+			}
+		}
+		visit("")
+
+		var cycle []string
+		for _, k := range m.keys {
+			if m.kept[k] && !reachable[k] {
+				cycle = append(cycle, k)
+			}
+		}
+		fixed := false
+		for _, k := range cycle {
+			if o := m.ours.index[k]; o != nil && m.parent[k] != o.parent {
+				m.parent[k] = o.parent
+				m.conflicts = append(m.conflicts, Conflict{Kind: ConflictMove, Key: k, Ours: o.parent})
+				fixed = true
+				break
+			}
+		}
+		if !fixed {
+			return
+		}
+	}
+}
+
+// childKeys returns the keys of the merged children of parent, in order.
+func (m *merger) childKeys(parent string) []string {
+	var keys []string
+	placed := make(map[string]bool)
+	for _, k := range m.ours.children[parent] {
+		if m.kept[k] && m.parent[k] == parent && !(m.theirs.moved[k] && !m.ours.moved[k]) {
+			keys = append(keys, k)
+			placed[k] = true
+		}
+	}
+
+	for _, e := range m.theirs.entries {
+		k := e.key
+		if placed[k] || !m.kept[k] || m.parent[k] != parent {
+			continue
+		}
+		i := len(keys)
+		if e.prev == "" || e.parent != parent {
+			i = 0
+		}
+		for j, s := range keys {
+			if s == e.prev {
+				i = j + 1
+			}
+		}
+		keys = append(keys, "")
+		copy(keys[i+1:], keys[i:])
+		keys[i] = k
+		placed[k] = true
+	}
+	return keys
+}
+
+// build returns the merged children of parent.
+func (m *merger) build(parent string) []Outline {
+	var outlines []Outline
+	for _, k := range m.childKeys(parent) {
+		o := m.mergeOutline(k)
+		o.Outlines = m.build(k)
+		outlines = append(outlines, o)
+	}
+	return outlines
+}
+
+// mergeOutline returns the merged attributes and unknown elements of an
+// outline.
+func (m *merger) mergeOutline(key string) Outline {
+	b, o, t := m.base.index[key], m.ours.index[key], m.theirs.index[key]
+	if o == nil || t == nil {
+		merged := m.side(key).index[key].attrs()
+		merged.Attrs = cloneAttrs(merged.Attrs)
+		merged.Elements = cloneElements(merged.Elements)
+		return merged
+	}
+
+	merged := o.attrs()
+	merged.Attrs = cloneAttrs(merged.Attrs)
+	base := &Outline{}
+	if b != nil {
+		base = b.outline
+	}
+	for _, name := range attrNames(base, o.outline, t.outline) {
+		v := m.mergeValue(key, name, base.Attr(name), o.outline.Attr(name), t.outline.Attr(name))
+		merged.SetAttr(name, v)
+	}
+	if reflect.DeepEqual(o.outline.Elements, base.Elements) {
+		merged.Elements = t.outline.Elements
+	}
+	merged.Elements = cloneElements(merged.Elements)
+	return merged
+}
+
+func (m *merger) mergeHead(b, o, t *Head) Head {
+	h := *o
+	for _, e := range headElements {
+		if e.name == "expansionState" {
+			// Set from the merged outlines.
+			continue
+		}
+		*e.field(&h) = m.mergeValue("", e.name, *e.field(b), *e.field(o), *e.field(t))
+	}
+	if reflect.DeepEqual(o.Elements, b.Elements) {
+		h.Elements = t.Elements
+	}
+	h.Attrs = cloneAttrs(h.Attrs)
+	h.Elements = cloneElements(h.Elements)
+	return h
+}
+
+// mergeValue returns the merged value, recording a conflict if both sides
+// changed it differently.
+func (m *merger) mergeValue(key, name, base, ours, theirs string) string {
+	switch {
+	case ours == theirs, theirs == base:
+		return ours
+	case ours == base:
+		return theirs
+	}
+	m.conflicts = append(m.conflicts, Conflict{
+		Kind: ConflictEdit, Key: key, Name: name, Base: base, Ours: ours, Theirs: theirs})
+	return ours
+}
+
+// setExpansion sets the expansion state of merged from the outlines expanded
+// in s.
+func (m *merger) setExpansion(merged *OPML, s *mergeSide) {
+	exp := s.doc.expansion()
+	if exp == nil {
+		merged.Head.ExpansionState = ""
+		return
+	}
+	expanded := make(map[string]bool)
+	for _, e := range s.entries {
+		if exp.contains(e.path) {
+			expanded[e.key] = true
+		}
+	}
+
+	entries, _ := diffIndex(merged)
+	paths := expansionPaths{}
+	for _, e := range entries {
+		if expanded[e.key] {
+			paths = append(paths, e.path)
+		}
+	}
+	merged.setExpansion(&paths)
+}
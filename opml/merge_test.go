@@ -0,0 +1,123 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base := patchTestDoc()
+	base.Head.ExpansionState = "1"
+
+	ours := base.Clone()
+	ours.Insert([]int{0, 3}, feed("Zig"))
+	ours.Body.Outlines[0].Outlines[0].Title = "Golang"
+	ours.Head.SetExpansion([]int{1, 6})
+
+	theirs := base.Clone()
+	theirs.Move([]int{0, 1}, []int{1, 1})
+	theirs.Body.Outlines[1].Outlines[0].Title = "Hacker News"
+	theirs.Insert([]int{1, 0}, feed("Lobsters"))
+	theirs.Remove([]int{2})
+	theirs.Head.Title = "Their feeds"
+
+	before := []string{}
+	for _, doc := range []*OPML{base, ours, theirs} {
+		s, _ := doc.XML()
+		before = append(before, s)
+	}
+
+	merged, conflicts := Merge3(base, ours, theirs)
+	if len(conflicts) != 0 {
+		t.Errorf("Unexpected conflicts: %v", conflicts)
+	}
+	if tree := texts(merged.Body.Outlines); tree != "Tech(Go Py Zig) News(Lobsters HN Rust)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if merged.Head.Title != "Their feeds" {
+		t.Errorf("Wrong title: found '%s'", merged.Head.Title)
+	}
+	if o := merged.Body.Outlines[0].Outlines[0]; o.Title != "Golang" {
+		t.Errorf("Wrong outline title: found '%s'", o.Title)
+	}
+	if o := merged.Body.Outlines[1].Outlines[1]; o.Title != "Hacker News" {
+		t.Errorf("Wrong outline title: found '%s'", o.Title)
+	}
+
+	// Tech and News are expanded in ours.
+	if merged.Head.ExpansionState != "1, 5" {
+		t.Errorf("Wrong expansion state: found '%s'", merged.Head.ExpansionState)
+	}
+
+	for i, doc := range []*OPML{base, ours, theirs} {
+		if s, _ := doc.XML(); s != before[i] {
+			t.Errorf("Document %d modified by Merge3", i)
+		}
+	}
+}
+
+func TestMerge3Conflicts(t *testing.T) {
+	base := patchTestDoc()
+
+	ours := base.Clone()
+	ours.Head.Title = "Ours"
+	ours.Body.Outlines[0].Outlines[0].Title = "Golang"
+	ours.Remove([]int{0, 2})
+	ours.Move([]int{0, 1}, []int{1, 1})
+	ours.Insert([]int{2, 1}, feed("Fark"))
+
+	theirs := base.Clone()
+	theirs.Head.Title = "Theirs"
+	theirs.Body.Outlines[0].Outlines[0].Title = "Go blog"
+	theirs.Body.Outlines[0].Outlines[2].Title = "Python"
+	theirs.Remove([]int{2})
+	theirs.Move([]int{0, 1}, []int{2})
+
+	merged, conflicts := Merge3(base, ours, theirs)
+	if tree := texts(merged.Body.Outlines); tree != "Tech(Go Py) News(HN Rust) Old(Fark)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+
+	var kinds []string
+	for _, c := range conflicts {
+		kinds = append(kinds, c.String())
+	}
+	expected := []string{
+		`edit conflict on title: ours "Ours", theirs "Theirs"`,
+		`move conflict on xmlUrl:http://example.com/Rust: ours under "text:News", theirs under ""`,
+		`delete conflict on xmlUrl:http://example.com/Py`,
+		`delete conflict on text:Old`,
+		`edit conflict on title of xmlUrl:http://example.com/Go: ours "Golang", theirs "Go blog"`,
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Wrong conflicts: expected\n%q\nfound\n%q", expected, kinds)
+	}
+	if c := conflicts[2]; !reflect.DeepEqual(c.Path, []int{0, 1}) {
+		t.Errorf("Wrong conflict path: %v", c.Path)
+	}
+	if o := merged.Body.Outlines[0].Outlines[0]; o.Title != "Golang" {
+		t.Errorf("Wrong outline title: found '%s'", o.Title)
+	}
+}
+
+func TestMerge3Cycle(t *testing.T) {
+	base := &OPML{Body: Body{Outlines: []Outline{feed("A"), feed("B")}}}
+
+	ours := base.Clone()
+	ours.Reparent([]int{0}, []int{1})
+
+	theirs := base.Clone()
+	theirs.Reparent([]int{1}, []int{0})
+
+	merged, conflicts := Merge3(base, ours, theirs)
+	if tree := texts(merged.Body.Outlines); tree != "B(A)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if len(conflicts) != 1 || conflicts[0].Kind != ConflictMove {
+		t.Errorf("Wrong conflicts: %v", conflicts)
+	}
+}
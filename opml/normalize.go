@@ -0,0 +1,199 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"net/url"
+	"strings"
+)
+
+// A Normalizer rewrites feed URLs so that URLs of the same feed compare
+// equal. Each field enables a rewriting rule.
+type Normalizer struct {
+	// HTTPS rewrites http URLs as https ones.
+	HTTPS bool
+
+	// TrailingSlash removes the trailing slash of the path.
+	TrailingSlash bool
+
+	// LowerHost writes the host in lower case.
+	LowerHost bool
+
+	// FeedBurner rewrites the URLs of feeds served through FeedBurner
+	// (feeds.feedburner.com, feeds2.feedburner.com or feedproxy.google.com)
+	// as feeds.feedburner.com URLs, without query.
+	FeedBurner bool
+
+	// StripWWW removes the "www." prefix of the host.
+	StripWWW bool
+
+	// DefaultPort removes the port when it is the default one of the
+	// scheme, i.e. 80 for http and 443 for https.
+	DefaultPort bool
+
+	// TrackingParams removes the query parameters used to track visitors:
+	// the ones starting with "utm_" and the ones listed in TrackingQueryParams.
+	TrackingParams bool
+
+	// PercentEncoding writes the path and query with the same
+	// percent-encoding: only the characters that must be are escaped, with
+	// upper case hexadecimal digits.
+	PercentEncoding bool
+}
+
+// DefaultNormalizer enables all the rewriting rules.
+var DefaultNormalizer = Normalizer{
+	HTTPS:           true,
+	TrailingSlash:   true,
+	LowerHost:       true,
+	FeedBurner:      true,
+	StripWWW:        true,
+	DefaultPort:     true,
+	TrackingParams:  true,
+	PercentEncoding: true,
+}
+
+// TrackingQueryParams lists the query parameters removed by the TrackingParams
+// rule of a Normalizer, besides the ones starting with "utm_".
+var TrackingQueryParams = []string{"fbclid", "gclid", "mc_cid", "mc_eid", "_hsenc", "_hsmi"}
+
+// feedBurnerHosts lists the hosts serving FeedBurner feeds.
+var feedBurnerHosts = map[string]bool{
+	"feeds.feedburner.com":  true,
+	"feeds2.feedburner.com": true,
+	"feedproxy.google.com":  true,
+}
+
+// defaultPorts maps schemes to their default port.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize returns the normalized form of the URL. Surrounding whitespace
+// is always removed. Values that are not absolute URLs are returned without
+// other change.
+func (n Normalizer) Normalize(rawurl string) string {
+	rawurl = strings.TrimSpace(rawurl)
+	u, err := url.Parse(rawurl)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return rawurl
+	}
+
+	if n.DefaultPort && u.Port() != "" && u.Port() == defaultPorts[u.Scheme] {
+		u.Host = u.Hostname()
+		if strings.Contains(u.Host, ":") {
+			// IPv6 address.
+			u.Host = "[" + u.Host + "]"
+		}
+	}
+	if n.HTTPS && u.Scheme == "http" {
+		u.Scheme = "https"
+	}
+	if n.LowerHost {
+		u.Host = strings.ToLower(u.Host)
+	}
+	if n.StripWWW && strings.HasPrefix(strings.ToLower(u.Host), "www.") {
+		u.Host = u.Host[len("www."):]
+	}
+	if n.TrailingSlash && strings.HasSuffix(u.Path, "/") {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = ""
+	}
+	if n.PercentEncoding && !strings.Contains(strings.ToUpper(u.RawPath), "%2F") {
+		// Without RawPath, the path is escaped by u.String. Escaped
+		// slashes are kept, since they differ from path separators.
+		u.RawPath = ""
+	}
+	if n.TrackingParams || n.PercentEncoding {
+		u.RawQuery = n.query(u.RawQuery)
+	}
+	if n.FeedBurner && feedBurnerHosts[strings.ToLower(u.Host)] {
+		u.Host = "feeds.feedburner.com"
+		u.RawQuery = ""
+		u.ForceQuery = false
+	}
+	return u.String()
+}
+
+// query rewrites a raw query, keeping the order of the parameters.
+func (n Normalizer) query(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	var params []string
+	for _, p := range strings.Split(raw, "&") {
+		key, value := p, ""
+		hasValue := false
+		if i := strings.IndexByte(p, '='); i >= 0 {
+			key, value, hasValue = p[:i], p[i+1:], true
+		}
+		if n.TrackingParams && isTrackingParam(unescapeQuery(key)) {
+			continue
+		}
+		if n.PercentEncoding {
+			key = url.QueryEscape(unescapeQuery(key))
+			value = url.QueryEscape(unescapeQuery(value))
+		}
+		if hasValue {
+			p = key + "=" + value
+		} else {
+			p = key
+		}
+		params = append(params, p)
+	}
+	return strings.Join(params, "&")
+}
+
+func unescapeQuery(s string) string {
+	if u, err := url.QueryUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+func isTrackingParam(key string) bool {
+	if strings.HasPrefix(key, "utm_") {
+		return true
+	}
+	for _, p := range TrackingQueryParams {
+		if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Equal reports whether both URLs have the same normalized form.
+func (n Normalizer) Equal(a, b string) bool {
+	return n.Normalize(a) == n.Normalize(b)
+}
+
+// URLChange describes a URL rewritten by Normalizer.Apply.
+type URLChange struct {
+	Path     []int  // index path of the outline
+	Attr     string // name of the attribute: "xmlUrl", "htmlUrl" or "url"
+	Old, New string
+}
+
+// Apply normalizes the xmlUrl, htmlUrl and url attributes of the outlines of
+// doc, and returns the changes made, in document order.
+func (n Normalizer) Apply(doc *OPML) []URLChange {
+	var changes []URLChange
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		for _, attr := range []string{"xmlUrl", "htmlUrl", "url"} {
+			f := o.knownAttr(attr)
+			if *f == "" {
+				continue
+			}
+			if u := n.Normalize(*f); u != *f {
+				changes = append(changes, URLChange{copyPath(path), attr, *f, u})
+				*f = u
+			}
+		}
+		return nil
+	})
+	return changes
+}
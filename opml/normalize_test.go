@@ -0,0 +1,71 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		n        Normalizer
+		url      string
+		expected string
+	}{
+		{DefaultNormalizer, " http://Example.COM/feed/ ", "https://example.com/feed"},
+		{DefaultNormalizer, "https://example.com/", "https://example.com"},
+		{DefaultNormalizer, "http://feedproxy.google.com/GoBlog?format=xml", "https://feeds.feedburner.com/GoBlog"},
+		{DefaultNormalizer, "http://feeds2.feedburner.com/GoBlog/", "https://feeds.feedburner.com/GoBlog"},
+		{DefaultNormalizer, "feed.xml", "feed.xml"},
+		{DefaultNormalizer, "ftp://example.com/feed?a=1", "ftp://example.com/feed?a=1"},
+		{Normalizer{}, "http://Example.com/feed/", "http://Example.com/feed/"},
+		{Normalizer{LowerHost: true}, "http://Example.com/Feed/", "http://example.com/Feed/"},
+		{Normalizer{FeedBurner: true}, "http://FeedProxy.Google.com/x?format=xml", "http://feeds.feedburner.com/x"},
+		{DefaultNormalizer, "http://www.Example.com:80/a%7eb/feed?utm_source=x&id=1&fbclid=2", "https://example.com/a~b/feed?id=1"},
+		{DefaultNormalizer, "https://[::1]:443/feed?q=a%20b&r=%2f", "https://[::1]/feed?q=a+b&r=%2F"},
+		{DefaultNormalizer, "https://example.com/a%2Fb?utm_medium=rss", "https://example.com/a%2Fb"},
+		{Normalizer{StripWWW: true}, "http://WWW.example.com/", "http://example.com/"},
+		{Normalizer{DefaultPort: true}, "https://example.com:80/", "https://example.com:80/"},
+		{Normalizer{DefaultPort: true}, "https://example.com:443/", "https://example.com/"},
+		{Normalizer{TrackingParams: true}, "http://example.com/?utm_campaign=a&b=%7e", "http://example.com/?b=%7e"},
+		{Normalizer{PercentEncoding: true}, "http://example.com/%7e?b=%7e&c", "http://example.com/~?b=~&c"},
+	}
+
+	for _, test := range tests {
+		if u := test.n.Normalize(test.url); u != test.expected {
+			t.Errorf("%+v: %q: expected %q, found %q", test.n, test.url, test.expected, u)
+		}
+		if u := test.n.Normalize(test.expected); u != test.expected {
+			t.Errorf("%+v: %q: not idempotent: found %q", test.n, test.expected, u)
+		}
+	}
+
+	if !DefaultNormalizer.Equal("http://www.example.com/feed/", "https://example.com/feed") {
+		t.Error("Expected equal URLs")
+	}
+}
+
+func TestNormalizerApply(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		folder("Tech", Outline{Text: "Go", XMLURL: "http://go.dev/feed/", HTMLURL: "https://go.dev/blog"}),
+		{Text: "Docs", URL: "HTTPS://WWW.go.dev/doc?utm_source=opml"},
+	}}}
+
+	changes := DefaultNormalizer.Apply(doc)
+	expected := []URLChange{
+		{[]int{0, 0}, "xmlUrl", "http://go.dev/feed/", "https://go.dev/feed"},
+		{[]int{1}, "url", "HTTPS://WWW.go.dev/doc?utm_source=opml", "https://go.dev/doc"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Wrong changes: expected %v, found %v", expected, changes)
+	}
+	if doc.Body.Outlines[0].Outlines[0].XMLURL != "https://go.dev/feed" || doc.Body.Outlines[1].URL != "https://go.dev/doc" {
+		t.Errorf("URLs not rewritten: %+v", doc.Body.Outlines)
+	}
+	if changes := DefaultNormalizer.Apply(doc); len(changes) != 0 {
+		t.Errorf("Unexpected changes: %v", changes)
+	}
+}
@@ -14,18 +14,26 @@ It is able to parse both, OPML 1.0 and OPML 2.0, files.
 package opml
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
-	"io/ioutil"
-	"net/http"
+	"io"
+	"os"
 )
 
 // OPML is the root node of an OPML document. It only has a single required
 // attribute: the version.
 type OPML struct {
-	XMLName xml.Name `xml:"opml" json:"opml"`
-	Version string   `xml:"version,attr" json:"version"`
-	Head    Head     `xml:"head" json:"head"`
-	Body    Body     `xml:"body" json:"body"`
+	XMLName xml.Name   `xml:"opml" json:"opml"`
+	Version string     `xml:"version,attr" json:"version"`
+	Head    Head       `xml:"head" json:"head"`
+	Body    Body       `xml:"body" json:"body"`
+	Attrs   []xml.Attr `xml:",any,attr" json:"attrs,omitempty"`
+
+	// Encoding is the character encoding of the document when it is not
+	// UTF-8, e.g. "ISO-8859-1". It is set when parsing a document and XML
+	// writes the document in that encoding.
+	Encoding string `xml:"-" json:"-"`
 }
 
 // Head holds some meta information about the document.
@@ -38,11 +46,16 @@ type Head struct {
 	OwnerID         string `xml:"ownerId,omitempty" json:"ownerId,omitempty"`
 	Docs            string `xml:"docs,omitempty" json:"docs,omitempty"`
 	ExpansionState  string `xml:"expansionState,omitempty" json:"expansionState,omitempty"`
-	VertScrollState string `xml:"vertScrollState,omitempty json:"verScrollState,omitempty"`
+	VertScrollState string `xml:"vertScrollState,omitempty" json:"vertScrollState,omitempty"`
 	WindowTop       string `xml:"windowTop,omitempty" json:"windowTop,omitempty"`
 	WindowBottom    string `xml:"windowBottom,omitempty" json:"windowBottom,omitempty"`
 	WindowLeft      string `xml:"windowLeft,omitempty" json:"windowLeft,omitempty"`
 	WindowRight     string `xml:"windowRight,omitempty" json:"windowRight,omitempty"`
+
+	// Attrs and Elements hold the attributes and child elements unknown to
+	// this package, so that they are written back by XML.
+	Attrs    []xml.Attr `xml:",any,attr" json:"attrs,omitempty"`
+	Elements []Element  `xml:",any" json:"elements,omitempty"`
 }
 
 // Body is the parent structure of all outlines.
@@ -66,43 +79,57 @@ type Outline struct {
 	Title        string    `xml:"title,attr,omitempty" json:"title,omitempty"`
 	Version      string    `xml:"version,attr,omitempty" json:"version,omitempty"`
 	Description  string    `xml:"description,attr,omitempty" json:"description,omitempty"`
+
+	// Attrs and Elements hold the attributes and child elements unknown to
+	// this package, so that they are written back by XML.
+	Attrs    []xml.Attr `xml:",any,attr" json:"attrs,omitempty"`
+	Elements []Element  `xml:",any" json:"elements,omitempty"`
 }
 
-// NewOPML creates a new OPML structure from a slice of bytes.
-func NewOPML(b []byte) (*OPML, error) {
-	var root OPML
-	err := xml.Unmarshal(b, &root)
-	if err != nil {
-		return nil, err
+// UnmarshalXML implements the xml.Unmarshaler interface. Prefixed names of
+// unknown attributes and elements are kept as written in the document, so
+// that they can be written back as is.
+func (doc *OPML) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type opml OPML
+	if err := d.DecodeElement((*opml)(doc), &start); err != nil {
+		return err
 	}
 
-	return &root, nil
+	decls := declarations(nil, doc.Attrs)
+	doc.Attrs = unresolveAttrs(doc.Attrs, decls)
+	doc.Head.unresolve(decls)
+	unresolveOutlines(doc.Body.Outlines, decls)
+	return nil
 }
 
-// NewOPMLFromURL creates a new OPML structure from an URL.
-func NewOPMLFromURL(url string) (*OPML, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// NewOPML creates a new OPML structure from a slice of bytes. Documents that
+// are not encoded in UTF-8 are converted according to their byte order mark
+// or XML declaration.
+func NewOPML(b []byte) (*OPML, error) {
+	return decode(bytes.NewReader(b), "")
+}
 
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+// NewOPMLFromReader creates a new OPML structure from a reader. The input is
+// decoded as it is read, without being buffered entirely in memory first.
+func NewOPMLFromReader(r io.Reader) (*OPML, error) {
+	return decode(r, "")
+}
 
-	return NewOPML(b)
+// NewOPMLFromURL creates a new OPML structure from an URL. It uses a Fetcher
+// with default settings: see Fetcher for more control over the request.
+func NewOPMLFromURL(url string) (*OPML, error) {
+	return new(Fetcher).Fetch(context.Background(), url)
 }
 
 // NewOPMLFromFile creates a new OPML structure from a file.
 func NewOPMLFromFile(filePath string) (*OPML, error) {
-	b, err := ioutil.ReadFile(filePath)
+	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	return NewOPML(b)
+	return NewOPMLFromReader(f)
 }
 
 // Outlines returns a slice of the outlines.
@@ -110,8 +137,13 @@ func (doc OPML) Outlines() []Outline {
 	return doc.Body.Outlines
 }
 
-// XML exports the OPML document to a XML string.
+// XML exports the OPML document to a XML string, in the encoding given by
+// doc.Encoding. It uses an Encoder with default settings: see Encoder for
+// more control over the output.
 func (doc OPML) XML() (string, error) {
-	b, err := xml.MarshalIndent(doc, "", "\t")
-	return xml.Header + string(b), err
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(&doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
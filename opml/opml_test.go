@@ -9,7 +9,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 )
 
@@ -80,7 +79,7 @@ func TestNewOPMLFromFile(t *testing.T) {
 func testNewOPMLFromURLSuccess(t *testing.T) {
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		b, err := ioutil.ReadFile(
-			os.Getenv("GOPATH") + "/src/github.com/gilliek/go-opml/testdata/feeds.xml")
+			"../testdata/feeds.xml")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -107,7 +106,7 @@ func testNewOPMLFromURLFailure(t *testing.T) {
 
 func testNewOPMLFromFileSuccess(t *testing.T) {
 	doc, err := NewOPMLFromFile(
-		os.Getenv("GOPATH") + "/src/github.com/gilliek/go-opml/testdata/feeds.xml")
+		"../testdata/feeds.xml")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -117,7 +116,7 @@ func testNewOPMLFromFileSuccess(t *testing.T) {
 
 func testNewOPMLFromFileFailure(t *testing.T) {
 	_, err := NewOPMLFromFile(
-		os.Getenv("GOPATH") + "/src/github.com/gilliek/go-opml/testdata/does_not_exist.xml")
+		"../testdata/does_not_exist.xml")
 	if err == nil {
 		t.Error("Expected failure!")
 	}
@@ -0,0 +1,379 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Attributes used by the org-mode conversion, besides NoteAttr and
+// CompleteAttr.
+const (
+	// TodoAttr holds the TODO keyword of a headline, e.g. "TODO" or "DONE".
+	// CompleteAttr is set along, to "true" for the keywords marking done
+	// items.
+	TodoAttr = "todo"
+
+	// PriorityAttr holds the priority of a headline, e.g. "A".
+	PriorityAttr = "priority"
+)
+
+var (
+	orgKeywordRE  = regexp.MustCompile(`^#\+(\w+):\s*(.*?)\s*$`)
+	orgHeadlineRE = regexp.MustCompile(`^(\*+)(?:\s+(.*?))?\s*$`)
+	orgPriorityRE = regexp.MustCompile(`^\[#([A-Za-z0-9])\](?:\s+|$)`)
+	orgTagsRE     = regexp.MustCompile(`(?:^|\s+)(:(?:[\w@#%]+:)+)$`)
+	orgTagRE      = regexp.MustCompile(`^[\w@#%]+$`)
+	orgPropertyRE = regexp.MustCompile(`^:(\S+?):(?:\s+(.*?))?\s*$`)
+
+	// orgEscapeRE matches the body lines escaped with a leading comma,
+	// once the comma is added.
+	orgEscapeRE = regexp.MustCompile(`^,*(?:\*|#\+)`)
+)
+
+// orgEscape escapes the start or end of headline text that would be read as
+// a TODO keyword, priority or tags. It is a zero-width space, as commonly
+// used to escape markup in org-mode.
+const orgEscape = "\u200b"
+
+// orgTodo holds the TODO keywords of an org file, mapped to whether they
+// mark done items.
+type orgTodo map[string]bool
+
+// defaultOrgTodo holds the TODO keywords of files without TODO setting.
+var defaultOrgTodo = orgTodo{"TODO": false, "DONE": true}
+
+// parse parses the value of a TODO setting, e.g. "TODO NEXT | DONE". Without
+// bar, the last keyword marks done items. Fast access keys, e.g. "TODO(t)",
+// are ignored.
+func (t orgTodo) parse(s string) {
+	s = strings.TrimSpace(s)
+	var active, done string
+	if i := strings.IndexByte(s, '|'); i >= 0 {
+		active, done = s[:i], s[i+1:]
+	} else if i := strings.LastIndexAny(s, " \t"); i >= 0 {
+		active, done = s[:i], s[i:]
+	} else {
+		active, done = "", s
+	}
+	for _, list := range []struct {
+		keywords string
+		done     bool
+	}{{active, false}, {done, true}} {
+		for _, k := range strings.Fields(list.keywords) {
+			if i := strings.IndexByte(k, '('); i >= 0 {
+				k = k[:i]
+			}
+			t[k] = list.done
+		}
+	}
+}
+
+// ParseOrg parses an outline written in Emacs org-mode. Headlines become
+// outlines, nested by level. The TODO keyword of a headline is kept in its
+// TodoAttr and CompleteAttr attributes, its priority in PriorityAttr and its
+// tags in the category attribute. The properties of its drawer become
+// attributes, and its body text the NoteAttr attribute. Body lines escaped
+// with a comma, e.g. ",* not a headline", are unescaped.
+//
+// The TITLE, AUTHOR and EMAIL keywords set the head of the document, and the
+// TODO, SEQ_TODO and TYP_TODO keywords the TODO keywords. Other text before
+// the first headline is ignored. Headline text escaped by WriteOrg is
+// unescaped.
+func ParseOrg(r io.Reader) (*OPML, error) {
+	doc := &OPML{Version: "2.0"}
+	todo := orgTodo{}
+	root := &textNode{}
+	stack := []*textNode{root}
+	var current *textNode
+	var body []string
+	inDrawer := false
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		for len(body) > 0 && strings.TrimSpace(body[0]) == "" {
+			body = body[1:]
+		}
+		for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+			body = body[:len(body)-1]
+		}
+		if len(body) > 0 {
+			current.outline.SetAttr(NoteAttr, strings.Join(body, "\n"))
+		}
+		body = nil
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	var lines []string
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		lines = append(lines, line)
+		// TODO keywords apply to the whole file.
+		if m := orgKeywordRE.FindStringSubmatch(line); m != nil {
+			switch strings.ToUpper(m[1]) {
+			case "TODO", "SEQ_TODO", "TYP_TODO":
+				todo.parse(m[2])
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(todo) == 0 {
+		todo = defaultOrgTodo
+	}
+
+	for _, line := range lines {
+		if m := orgHeadlineRE.FindStringSubmatch(line); m != nil {
+			flush()
+			n := &textNode{heading: len(m[1]), outline: orgHeadline(m[2], todo)}
+			for len(stack) > 1 && stack[len(stack)-1].heading >= n.heading {
+				stack = stack[:len(stack)-1]
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+			current = n
+			inDrawer = false
+			continue
+		}
+
+		if current == nil {
+			if m := orgKeywordRE.FindStringSubmatch(line); m != nil {
+				switch strings.ToUpper(m[1]) {
+				case "TITLE":
+					doc.Head.Title = m[2]
+				case "AUTHOR":
+					doc.Head.OwnerName = m[2]
+				case "EMAIL":
+					doc.Head.OwnerEmail = m[2]
+				}
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.EqualFold(trimmed, ":PROPERTIES:") && len(body) == 0 && !inDrawer:
+			inDrawer = true
+		case inDrawer && strings.EqualFold(trimmed, ":END:"):
+			inDrawer = false
+		case inDrawer:
+			if m := orgPropertyRE.FindStringSubmatch(trimmed); m != nil {
+				setOrgProperty(&current.outline, m[1], m[2])
+			}
+		default:
+			if strings.HasPrefix(line, ",") && orgEscapeRE.MatchString(line) {
+				line = line[1:]
+			}
+			body = append(body, line)
+		}
+	}
+	flush()
+
+	doc.Body.Outlines = root.outlines()
+	return doc, nil
+}
+
+// orgHeadline returns the outline of a headline, without its stars.
+func orgHeadline(s string, todo orgTodo) Outline {
+	var o Outline
+	if f := strings.Fields(s); len(f) > 0 {
+		if done, ok := todo[f[0]]; ok {
+			o.SetAttr(TodoAttr, f[0])
+			o.SetAttr(CompleteAttr, boolString(done))
+			s = strings.TrimLeft(s[len(f[0]):], " \t")
+		}
+	}
+	if m := orgPriorityRE.FindStringSubmatch(s); m != nil {
+		o.SetAttr(PriorityAttr, m[1])
+		s = s[len(m[0]):]
+	}
+	if m := orgTagsRE.FindStringSubmatch(s); m != nil {
+		var categories []Category
+		for _, tag := range strings.Split(strings.Trim(m[1], ":"), ":") {
+			categories = append(categories, Category{Path: []string{tag}, Tag: true})
+		}
+		o.SetCategories(categories)
+		s = s[:len(s)-len(m[0])]
+	}
+	s = strings.TrimPrefix(strings.TrimSpace(s), orgEscape)
+	o.Text = strings.TrimSuffix(s, orgEscape)
+	return o
+}
+
+// escapeOrgHeadline escapes the text of a headline, so that its start is not
+// read as a TODO keyword or priority, and its end as tags.
+func escapeOrgHeadline(s string, todo orgTodo) string {
+	if f := strings.Fields(s); len(f) > 0 {
+		if _, ok := todo[f[0]]; ok || orgPriorityRE.MatchString(s) || strings.HasPrefix(s, orgEscape) {
+			s = orgEscape + s
+		}
+	}
+	if orgTagsRE.MatchString(s) || strings.HasSuffix(s, orgEscape) {
+		s += orgEscape
+	}
+	return s
+}
+
+// setOrgProperty sets the attribute of a property. The category property is
+// merged with the tags of the headline.
+func setOrgProperty(o *Outline, name, value string) {
+	if name == "category" && o.Category != "" {
+		value = FormatCategories(append(ParseCategories(value), o.Categories()...))
+	}
+	o.SetAttr(name, value)
+}
+
+// WriteOrg writes doc in Emacs org-mode, as parsed by ParseOrg. Attributes
+// other than the ones mapped to the headline or body are written in
+// property drawers. Body lines starting with "*" or "#+" are escaped with a
+// comma, as by org-mode. Categories are written as tags, unless some of them
+// are paths or contain characters not allowed in tags, in which case the
+// category attribute is written in the drawer.
+// Outlines with a CompleteAttr attribute and no TodoAttr one are written
+// with the TODO or DONE keyword. Headline text that would be read as a
+// TODO keyword, priority or tags is escaped with a zero-width space.
+func WriteOrg(w io.Writer, doc *OPML) error {
+	var b strings.Builder
+	for _, k := range []struct{ name, value string }{
+		{"TITLE", doc.Head.Title},
+		{"AUTHOR", doc.Head.OwnerName},
+		{"EMAIL", doc.Head.OwnerEmail},
+	} {
+		if k.value != "" {
+			b.WriteString("#+" + k.name + ": " + k.value + "\n")
+		}
+	}
+	todo := defaultOrgTodo
+	if setting := orgTodoSetting(doc); setting != "" {
+		b.WriteString("#+TODO: " + setting + "\n")
+		todo = orgTodo{}
+		todo.parse(setting)
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		writeOrgHeadline(&b, o, depth, todo)
+		return nil
+	})
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// orgTodoSetting returns the TODO setting declaring the keywords used in doc,
+// along with the default ones, or the empty string if the default keywords
+// are enough.
+func orgTodoSetting(doc *OPML) string {
+	active, done := []string{"TODO"}, []string{"DONE"}
+	seen := map[string]bool{"TODO": true, "DONE": true}
+	custom := false
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		k := o.Attr(TodoAttr)
+		if k == "" || seen[k] {
+			return nil
+		}
+		seen[k] = true
+		isDone := o.Attr(CompleteAttr) == "true"
+		if isDone {
+			done = append(done, k)
+		} else {
+			active = append(active, k)
+		}
+		custom = true
+		return nil
+	})
+	if !custom {
+		return ""
+	}
+	return strings.Join(active, " ") + " | " + strings.Join(done, " ")
+}
+
+// orgLineReplacer replaces line breaks in property values.
+var orgLineReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+func writeOrgHeadline(b *strings.Builder, o *Outline, depth int, keywords orgTodo) {
+	b.WriteString(strings.Repeat("*", depth+1))
+
+	todo := o.Attr(TodoAttr)
+	if todo == "" {
+		switch o.Attr(CompleteAttr) {
+		case "true":
+			todo = "DONE"
+		case "false":
+			todo = "TODO"
+		}
+	}
+	if todo != "" {
+		b.WriteString(" " + todo)
+	}
+	if p := o.Attr(PriorityAttr); p != "" {
+		b.WriteString(" [#" + p + "]")
+	}
+	if o.Text != "" {
+		b.WriteString(" " + escapeOrgHeadline(o.Text, keywords))
+	}
+
+	categories := o.Categories()
+	tags := len(categories) > 0
+	for _, c := range categories {
+		if !c.Tag || !orgTagRE.MatchString(c.String()) {
+			tags = false
+		}
+	}
+	if tags {
+		b.WriteString(" :")
+		for _, c := range categories {
+			b.WriteString(c.String() + ":")
+		}
+	}
+	b.WriteString("\n")
+
+	var props []string
+	add := func(name, value string) {
+		switch name {
+		case "text", TodoAttr, CompleteAttr, PriorityAttr, NoteAttr:
+			return
+		case "category":
+			if tags {
+				return
+			}
+		}
+		if value != "" {
+			props = append(props, ":"+name+": "+orgLineReplacer.Replace(value))
+		}
+	}
+	for _, a := range outlineAttrs {
+		add(a.name, *a.field(o))
+	}
+	for _, a := range o.Attrs {
+		add(attrName(a.Name), a.Value)
+	}
+	if len(props) > 0 {
+		b.WriteString(":PROPERTIES:\n")
+		for _, p := range props {
+			b.WriteString(p + "\n")
+		}
+		b.WriteString(":END:\n")
+	}
+
+	if note := o.Attr(NoteAttr); note != "" {
+		for _, line := range strings.Split(note, "\n") {
+			if orgEscapeRE.MatchString(line) {
+				line = "," + line
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+}
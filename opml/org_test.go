@@ -0,0 +1,287 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const orgTestFile = `#+TITLE: Reading list
+#+AUTHOR: Jane Doe
+#+TODO: TODO NEXT | DONE CANCELED
+
+* Tech :programming:
+:PROPERTIES:
+:created: Fri, 01 Jan 2021 00:00:00 GMT
+:END:
+Things to read.
+
+Second paragraph.
+** NEXT [#A] Go blog :go:golang:
+:PROPERTIES:
+:type: rss
+:xmlUrl: https://go.dev/feed
+:custom: some value
+:END:
+** CANCELED Zig
+*** Nested
+** Rust
+:PROPERTIES:
+:category: /Tech/Lang,rust
+:END:
+* News
+`
+
+func TestParseOrg(t *testing.T) {
+	doc, err := ParseOrg(strings.NewReader(orgTestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Head.Title != "Reading list" || doc.Head.OwnerName != "Jane Doe" {
+		t.Errorf("Wrong head: %+v", doc.Head)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "Tech(Go blog Zig(Nested) Rust) News" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+
+	tech := doc.Body.Outlines[0]
+	if tech.Category != "programming" || tech.Created != "Fri, 01 Jan 2021 00:00:00 GMT" ||
+		tech.Attr(NoteAttr) != "Things to read.\n\nSecond paragraph." || tech.Attr(TodoAttr) != "" {
+		t.Errorf("Wrong headline: %+v", tech)
+	}
+	feed := tech.Outlines[0]
+	attrs := map[string]string{
+		"type": "rss", "xmlUrl": "https://go.dev/feed", "category": "go,golang", "custom": "some value",
+		TodoAttr: "NEXT", CompleteAttr: "false", PriorityAttr: "A", NoteAttr: "",
+	}
+	for name, value := range attrs {
+		if v := feed.Attr(name); v != value {
+			t.Errorf("Wrong %s: expected %q, found %q", name, value, v)
+		}
+	}
+	if zig := tech.Outlines[1]; zig.Attr(TodoAttr) != "CANCELED" || zig.Attr(CompleteAttr) != "true" {
+		t.Errorf("Wrong headline: %+v", zig)
+	}
+	if rust := tech.Outlines[2]; rust.Category != "/Tech/Lang,rust" {
+		t.Errorf("Wrong category: %q", rust.Category)
+	}
+}
+
+func TestOrgRoundTrip(t *testing.T) {
+	doc, err := ParseOrg(strings.NewReader(orgTestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := doc.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err = NewOPML([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := WriteOrg(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != orgTestFile {
+		t.Errorf("Wrong org file: expected\n%s\nfound\n%s", orgTestFile, b.String())
+	}
+}
+
+func TestParseOrgHeadlines(t *testing.T) {
+	tests := []struct {
+		line  string
+		attrs map[string]string
+	}{
+		{"* TODO Write", map[string]string{"text": "Write", TodoAttr: "TODO", CompleteAttr: "false"}},
+		{"* DONE", map[string]string{"text": "", TodoAttr: "DONE", CompleteAttr: "true"}},
+		{"* TODOS list", map[string]string{"text": "TODOS list", TodoAttr: ""}},
+		{"* [#B] Later :a:b@c:", map[string]string{"text": "Later", PriorityAttr: "B", "category": "a,b@c"}},
+		{"* Time 10:30", map[string]string{"text": "Time 10:30", "category": ""}},
+		{"*", map[string]string{"text": ""}},
+	}
+
+	for _, test := range tests {
+		doc, err := ParseOrg(strings.NewReader(test.line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(doc.Body.Outlines) != 1 {
+			t.Errorf("%q: expected one outline, found %d", test.line, len(doc.Body.Outlines))
+			continue
+		}
+		o := doc.Body.Outlines[0]
+		for name, value := range test.attrs {
+			if v := o.Attr(name); v != value {
+				t.Errorf("%q: wrong %s: expected %q, found %q", test.line, name, value, v)
+			}
+		}
+	}
+
+	doc, _ := ParseOrg(strings.NewReader("Preamble\n**bold** text\n"))
+	if len(doc.Body.Outlines) != 0 {
+		t.Errorf("Unexpected outlines: %+v", doc.Body.Outlines)
+	}
+}
+
+func TestWriteOrg(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		{Text: "Tasks", Category: "/Work,urgent", Outlines: []Outline{{Text: "Review"}, {Text: "Ship"}}},
+	}}}
+	doc.Body.Outlines[0].Outlines[0].SetAttr(CompleteAttr, "true")
+	doc.Body.Outlines[0].Outlines[1].SetAttr(CompleteAttr, "false")
+	doc.Body.Outlines[0].Outlines[1].SetAttr(NoteAttr, "Before\nFriday")
+	doc.Body.Outlines[0].Outlines[1].Description = "Two\nlines"
+
+	var b strings.Builder
+	if err := WriteOrg(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := `* Tasks
+:PROPERTIES:
+:category: /Work,urgent
+:END:
+** DONE Review
+** TODO Ship
+:PROPERTIES:
+:description: Two lines
+:END:
+Before
+Friday
+`
+	if b.String() != expected {
+		t.Errorf("Wrong org file: expected\n%s\nfound\n%s", expected, b.String())
+	}
+
+	parsed, err := ParseOrg(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := parsed.Body.Outlines[0].Outlines[1]; !reflect.DeepEqual(o.Categories(), []Category(nil)) || o.Attr(CompleteAttr) != "false" || o.Attr(NoteAttr) != "Before\nFriday" {
+		t.Errorf("Wrong outline: %+v", o)
+	}
+}
+
+func TestOrgEscaping(t *testing.T) {
+	note := "line1\n* star line\n#+TITLE: hijack\n,* comma\n#+TODO: A | B\n**bold**"
+	doc := &OPML{Body: Body{Outlines: []Outline{{Text: "Notes"}, {Text: "Next"}}}}
+	doc.Body.Outlines[0].SetAttr(NoteAttr, note)
+
+	var b strings.Builder
+	if err := WriteOrg(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := "* Notes\nline1\n,* star line\n,#+TITLE: hijack\n,,* comma\n,#+TODO: A | B\n,**bold**\n* Next\n"
+	if b.String() != expected {
+		t.Errorf("Wrong org file: expected\n%s\nfound\n%s", expected, b.String())
+	}
+
+	parsed, err := ParseOrg(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree := texts(parsed.Body.Outlines); tree != "Notes Next" || parsed.Head.Title != "" {
+		t.Errorf("Wrong document: '%s', %+v", tree, parsed.Head)
+	}
+	if n := parsed.Body.Outlines[0].Attr(NoteAttr); n != note {
+		t.Errorf("Wrong note: expected %q, found %q", note, n)
+	}
+	if o := parsed.Body.Outlines[1]; o.Attr(TodoAttr) != "" {
+		t.Errorf("Wrong headline: %+v", o)
+	}
+}
+
+func TestOrgTags(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{
+		{Text: "Tagged", Category: "my-tag,go"},
+		{Text: "Plain", Category: "go,b@c"},
+	}}}
+
+	var b strings.Builder
+	if err := WriteOrg(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := "* Tagged\n:PROPERTIES:\n:category: my-tag,go\n:END:\n* Plain :go:b@c:\n"
+	if b.String() != expected {
+		t.Errorf("Wrong org file: expected\n%s\nfound\n%s", expected, b.String())
+	}
+
+	parsed, err := ParseOrg(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, o := range parsed.Body.Outlines {
+		if o.Text != doc.Body.Outlines[i].Text || o.Category != doc.Body.Outlines[i].Category {
+			t.Errorf("Wrong outline: expected %+v, found %+v", doc.Body.Outlines[i], o)
+		}
+	}
+}
+
+func TestOrgNamespacedProperties(t *testing.T) {
+	doc := &OPML{Body: Body{Outlines: []Outline{{Text: "Show"}}}}
+	doc.Body.Outlines[0].Attrs = []xml.Attr{
+		{Name: xml.Name{Space: "podcast", Local: "guid"}, Value: "abc: def"},
+	}
+
+	var b strings.Builder
+	if err := WriteOrg(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := "* Show\n:PROPERTIES:\n:podcast:guid: abc: def\n:END:\n"
+	if b.String() != expected {
+		t.Errorf("Wrong org file: expected\n%s\nfound\n%s", expected, b.String())
+	}
+
+	parsed, err := ParseOrg(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := parsed.Body.Outlines[0].Attr("podcast:guid"); v != "abc: def" {
+		t.Errorf("Wrong attribute: expected 'abc: def', found '%s'", v)
+	}
+}
+
+func TestOrgHeadlineEscaping(t *testing.T) {
+	texts := []string{
+		"TODO list for Monday",
+		"[#A] grade",
+		"Meeting :work:",
+		"NEXT week",
+		orgEscape + "zero-width",
+		"ends" + orgEscape,
+	}
+	doc := &OPML{Body: Body{}}
+	for _, text := range texts {
+		doc.Body.Outlines = append(doc.Body.Outlines, Outline{Text: text})
+	}
+	doc.Body.Outlines[2].Category = "go"
+	doc.Body.Outlines[3].SetAttr(TodoAttr, "NEXT")
+	doc.Body.Outlines[3].SetAttr(CompleteAttr, "false")
+	doc.Body.Outlines[3].SetAttr(PriorityAttr, "B")
+
+	var b strings.Builder
+	if err := WriteOrg(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseOrg(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Body.Outlines) != len(texts) {
+		t.Fatalf("Wrong outlines: %+v", parsed.Body.Outlines)
+	}
+	for i, o := range parsed.Body.Outlines {
+		expected := doc.Body.Outlines[i]
+		if o.Text != expected.Text || o.Category != expected.Category ||
+			o.Attr(TodoAttr) != expected.Attr(TodoAttr) || o.Attr(PriorityAttr) != expected.Attr(PriorityAttr) {
+			t.Errorf("Wrong outline: expected %+v, found %+v", expected, o)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+// ErrConflict is returned, wrapped in a ConflictError, when an operation of a
+// patch does not apply to the document.
+var ErrConflict = errors.New("opml: patch conflict")
+
+// OpKind is the kind of a patch operation.
+type OpKind string
+
+// Kinds of patch operations.
+const (
+	OpAdd    OpKind = "add"
+	OpRemove OpKind = "remove"
+	OpMove   OpKind = "move"
+	OpSet    OpKind = "set"
+)
+
+// Op is an operation of a patch.
+//
+// Outlines are addressed by index path or by key, as defined by Compare.
+// When both are given, the outline at the path must have the key.
+// Destinations are given either by index path or by the keys of the new
+// parent and of the previous sibling, which are robust to concurrent edits.
+type Op struct {
+	Kind OpKind `json:"op"`
+
+	// Path and Key address the outline removed, moved or changed. Path is
+	// the insertion path of added outlines, and Key, if not empty, their key
+	// once added, e.g. the numbered key of a duplicate.
+	Path []int  `json:"path,omitempty"`
+	Key  string `json:"key,omitempty"`
+
+	// To is the destination path of moved outlines, interpreted once the
+	// outline has been removed from its original position, as by
+	// OPML.Move.
+	To []int `json:"to,omitempty"`
+
+	// Parent and After are the keys of the new parent and of the new
+	// previous sibling of outlines added without Path or moved without To.
+	// An empty Parent stands for the body, an empty After for the first
+	// position.
+	Parent string `json:"parent,omitempty"`
+	After  string `json:"after,omitempty"`
+
+	// Outline is the outline added, children included.
+	Outline *Outline `json:"outline,omitempty"`
+
+	// Name and Value are the name and new value of the attribute set, or of
+	// the head element set when no outline is addressed. An empty value
+	// unsets the attribute.
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+
+	// Old, if not nil, is the expected current value of the attribute or
+	// head element set.
+	Old *string `json:"old,omitempty"`
+}
+
+// Patch is a list of operations, applied in order. It is serialized as a
+// JSON array, e.g.
+//
+//	[
+//		{"op": "add", "parent": "text:Tech", "outline": {"text": "Go", "xmlUrl": "https://go.dev/blog/feed.atom"}},
+//		{"op": "move", "key": "xmlUrl:https://blog.rust-lang.org/feed.xml", "to": [1, 0]},
+//		{"op": "set", "path": [0, 2], "name": "title", "value": "Python", "old": "Py"},
+//		{"op": "remove", "key": "text:Old"}
+//	]
+type Patch []Op
+
+// ConflictError describes an operation of a patch that does not apply to the
+// document.
+type ConflictError struct {
+	Index int // index of the operation in the patch
+	Op    Op
+	Err   error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("opml: %s operation %d: %v", e.Op.Kind, e.Index, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// Apply applies the patch to doc. Adding an outline whose key is already used
+// in the document is a conflict, unless the operation gives the key of the
+// outline once added, e.g. "xmlUrl:https://go.dev/blog/feed.atom#2" for a
+// duplicate, in which case the outline must get this key. If an operation does not apply, doc is left
+// unchanged and a *ConflictError is returned.
+func Apply(doc *OPML, patch Patch) error {
+	c := doc.Clone()
+	for i, op := range patch {
+		if err := c.apply(op); err != nil {
+			return &ConflictError{i, op, err}
+		}
+	}
+	*doc = *c
+	return nil
+}
+
+func (doc *OPML) apply(op Op) error {
+	switch op.Kind {
+	case OpAdd:
+		if op.Outline == nil {
+			return fmt.Errorf("%w: no outline to add", ErrConflict)
+		}
+		to := op.Path
+		if to == nil {
+			var err error
+			if to, err = doc.destination(op); err != nil {
+				return err
+			}
+		}
+		key := doc.insertionKey(op.Outline, to)
+		if _, index := diffIndex(doc); op.Key == "" && index[key] != nil {
+			return fmt.Errorf("%w: outline %q already exists", ErrConflict, key)
+		}
+		if err := doc.Insert(to, *op.Outline); err != nil {
+			return err
+		}
+		if op.Key != "" {
+			if _, index := diffIndex(doc); index[op.Key] == nil || !equalPaths(index[op.Key].path, to) {
+				return fmt.Errorf("%w: outline %q not added as %q", ErrConflict, key, op.Key)
+			}
+		}
+		return nil
+
+	case OpRemove:
+		path, err := doc.target(op)
+		if err != nil {
+			return err
+		}
+		_, err = doc.Remove(path)
+		return err
+
+	case OpMove:
+		from, err := doc.target(op)
+		if err != nil {
+			return err
+		}
+		to := op.To
+		if to == nil {
+			if to, err = doc.destination(op); err != nil {
+				return err
+			}
+			n := len(to) - 1
+			parent, ok := adjustForRemoval(to[:n], from)
+			if !ok {
+				return fmt.Errorf("%w: cannot move %v into its own subtree", ErrInvalidMove, from)
+			}
+			i := to[n]
+			if isChild(from, to[:n]) && i > from[n] {
+				i--
+			}
+			to = append(copyPath(parent), i)
+		}
+		return doc.Move(from, to)
+
+	case OpSet:
+		if op.Path == nil && op.Key == "" {
+			f := doc.Head.field(xml.Name{Local: op.Name})
+			if f == nil {
+				return fmt.Errorf("%w: unknown head element %q", ErrConflict, op.Name)
+			}
+			if op.Old != nil && *f != *op.Old {
+				return fmt.Errorf("%w: %s is %q, expected %q", ErrConflict, op.Name, *f, *op.Old)
+			}
+			*f = op.Value
+			return nil
+		}
+		path, err := doc.target(op)
+		if err != nil {
+			return err
+		}
+		o, _ := doc.Outline(path)
+		if v := o.Attr(op.Name); op.Old != nil && v != *op.Old {
+			return fmt.Errorf("%w: %s of %v is %q, expected %q", ErrConflict, op.Name, path, v, *op.Old)
+		}
+		o.SetAttr(op.Name, op.Value)
+		return nil
+	}
+	return fmt.Errorf("%w: unknown operation %q", ErrConflict, op.Kind)
+}
+
+// target returns the path of the outline addressed by op.
+func (doc *OPML) target(op Op) ([]int, error) {
+	if op.Key == "" {
+		if _, err := doc.Outline(op.Path); err != nil {
+			return nil, err
+		}
+		return op.Path, nil
+	}
+
+	_, index := diffIndex(doc)
+	e := index[op.Key]
+	if e == nil {
+		return nil, fmt.Errorf("%w: no outline %q", ErrConflict, op.Key)
+	}
+	if op.Path != nil && !equalPaths(op.Path, e.path) {
+		return nil, fmt.Errorf("%w: outline %q is at %v, not %v", ErrConflict, op.Key, e.path, op.Path)
+	}
+	return e.path, nil
+}
+
+// destination returns the insertion path given by op.Parent and op.After.
+func (doc *OPML) destination(op Op) ([]int, error) {
+	_, index := diffIndex(doc)
+	var parent []int
+	if op.Parent != "" {
+		e := index[op.Parent]
+		if e == nil {
+			return nil, fmt.Errorf("%w: no parent outline %q", ErrConflict, op.Parent)
+		}
+		parent = e.path
+	}
+	if op.After == "" {
+		return append(copyPath(parent), 0), nil
+	}
+	e := index[op.After]
+	if e == nil || !isChild(e.path, parent) {
+		return nil, fmt.Errorf("%w: no outline %q under %q", ErrConflict, op.After, op.Parent)
+	}
+	to := copyPath(e.path)
+	to[len(to)-1]++
+	return to, nil
+}
+
+// insertionKey returns the key of o once inserted at path to, before
+// numbering.
+func (doc *OPML) insertionKey(o *Outline, to []int) string {
+	var texts []string
+	if len(to) > 1 {
+		texts = doc.ancestorTexts(to[:len(to)-1])
+	}
+	return outlineKey(o, append(texts, o.Text))
+}
+
+// ancestorTexts returns the texts of the outline at path and its ancestors.
+func (doc *OPML) ancestorTexts(path []int) []string {
+	var texts []string
+	for i := range path {
+		o, err := doc.Outline(path[:i+1])
+		if err != nil {
+			return nil
+		}
+		texts = append(texts, o.Text)
+	}
+	return texts
+}
+
+func equalPaths(a, b []int) bool {
+	return len(a) == len(b) && hasPrefix(a, b)
+}
+
+// MakePatch returns a patch turning document a into document b, according to
+// the changes reported by Compare. Outlines are addressed by key, and set
+// operations hold the old values, so that applying the patch to a document
+// that has diverged from a fails instead of overwriting its changes.
+func MakePatch(a, b *OPML) Patch {
+	d := Compare(a, b)
+	var patch Patch
+	for _, c := range d.Head {
+		old := c.Old
+		patch = append(patch, Op{Kind: OpSet, Name: c.Name, Value: c.New, Old: &old})
+	}
+
+	// The operations are applied to a copy of a as they are made, so that
+	// outlines are addressed by their key at that point: keys change with
+	// the texts of ancestors and the numbering of duplicates. The outlines of
+	// the copy are marked with their key in a, or in b for the added ones.
+	p := patcher{doc: a.Clone()}
+	entries, oldIndex := diffIndex(p.doc)
+	for _, e := range entries {
+		e.outline.SetAttr(patchMark, e.key)
+	}
+	_, index := diffIndex(b)
+
+	// Outlines are removed first, deepest first, since they are addressed
+	// in a. The descendants of a removed outline are removed with it, except
+	// the ones kept, first moved to the end of the body: they are moved to
+	// their place afterwards, their parent having changed.
+	removed := make(map[string]bool)
+	var removals []string
+	for _, c := range d.Outlines {
+		if c.Kind == Removed {
+			removed[c.Key] = true
+			if !removed[oldIndex[c.Key].parent] {
+				removals = append(removals, c.Key)
+			}
+		}
+	}
+	for _, e := range entries {
+		if removed[e.parent] && !removed[e.key] {
+			last := p.doc.Body.Outlines[len(p.doc.Body.Outlines)-1].Attr(patchMark)
+			p.add(Op{Kind: OpMove, Key: e.key, After: last})
+		}
+	}
+	for i := len(removals) - 1; i >= 0; i-- {
+		p.add(Op{Kind: OpRemove, Key: removals[i]})
+	}
+
+	// Outlines are added and moved in the order of b, so that their parent
+	// and previous sibling are in place.
+	for _, c := range d.Outlines {
+		switch c.Kind {
+		case Added:
+			e := index[c.Key]
+			o := c.Outline
+			p.insert(c.Key, Op{Kind: OpAdd, Parent: e.parent, After: e.prev, Outline: &o})
+		case Moved:
+			e := index[c.Key]
+			p.add(Op{Kind: OpMove, Key: c.Key, Parent: e.parent, After: e.prev})
+		case Modified:
+			for _, attr := range c.Attrs {
+				old := attr.Old
+				p.add(Op{Kind: OpSet, Key: c.Key, Name: attr.Name, Value: attr.New, Old: &old})
+			}
+		}
+	}
+	return append(patch, p.patch...)
+}
+
+// patchMark is the attribute marking the outlines of the document patched
+// by MakePatch. Its name is not a valid XML name, so it does not clash with
+// the attributes of the outlines.
+const patchMark = " key"
+
+// patcher makes the outline operations of a patch, applying them to doc.
+type patcher struct {
+	doc   *OPML
+	patch Patch
+}
+
+// keys returns the current keys of the outlines of doc, by mark.
+func (p *patcher) keys() map[string]string {
+	keys := map[string]string{"": ""}
+	entries, _ := diffIndex(p.doc)
+	for _, e := range entries {
+		keys[e.outline.Attr(patchMark)] = e.key
+	}
+	return keys
+}
+
+// add adds an operation whose keys are marks, once converted to the
+// current keys.
+func (p *patcher) add(op Op) {
+	keys := p.keys()
+	op.Key, op.Parent, op.After = keys[op.Key], keys[op.Parent], keys[op.After]
+	p.doc.apply(op)
+	p.patch = append(p.patch, op)
+}
+
+// insert adds an add operation for the outline with the given mark. The
+// key of the outline is given when it is already used in doc, e.g. by a
+// duplicate.
+func (p *patcher) insert(mark string, op Op) {
+	keys := p.keys()
+	op.Parent, op.After = keys[op.Parent], keys[op.After]
+	if to, err := p.doc.destination(op); err == nil {
+		_, index := diffIndex(p.doc)
+		used := index[p.doc.insertionKey(op.Outline, to)] != nil
+		o := cloneOutlines([]Outline{*op.Outline})[0]
+		o.SetAttr(patchMark, mark)
+		p.doc.Insert(to, o)
+		if used {
+			op.Key = p.keys()[mark]
+		}
+	}
+	p.patch = append(p.patch, op)
+}
@@ -0,0 +1,250 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func patchTestDoc() *OPML {
+	return &OPML{
+		Head: Head{Title: "Feeds"},
+		Body: Body{Outlines: []Outline{
+			folder("Tech", feed("Go"), feed("Rust"), feed("Py")),
+			folder("News", feed("HN")),
+			folder("Old", feed("Slashdot")),
+		}},
+	}
+}
+
+func TestApply(t *testing.T) {
+	var patch Patch
+	err := json.Unmarshal([]byte(`[
+		{"op": "add", "parent": "text:News", "after": "xmlUrl:http://example.com/HN", "outline": {"text": "Lobsters"}},
+		{"op": "move", "key": "xmlUrl:http://example.com/Rust", "to": [1, 0]},
+		{"op": "move", "key": "xmlUrl:http://example.com/Slashdot", "parent": "text:News", "after": "text:News/Lobsters"},
+		{"op": "set", "path": [0, 1], "name": "title", "value": "Python", "old": ""},
+		{"op": "set", "name": "title", "value": "My feeds", "old": "Feeds"},
+		{"op": "remove", "key": "text:Old"}
+	]`), &patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := patchTestDoc()
+	if err := Apply(doc, patch); err != nil {
+		t.Fatal(err)
+	}
+	if tree := texts(doc.Body.Outlines); tree != "Tech(Go Py) News(Rust HN Lobsters Slashdot)" {
+		t.Errorf("Wrong tree: found '%s'", tree)
+	}
+	if doc.Head.Title != "My feeds" || doc.Body.Outlines[0].Outlines[1].Title != "Python" {
+		t.Errorf("Wrong values: %+v", doc)
+	}
+}
+
+func TestApplyConflict(t *testing.T) {
+	wrong := "Golang"
+	tests := []struct {
+		name string
+		op   Op
+	}{
+		{"old value", Op{Kind: OpSet, Key: "xmlUrl:http://example.com/Go", Name: "title", Value: "Go", Old: &wrong}},
+		{"old head value", Op{Kind: OpSet, Name: "title", Value: "Go", Old: &wrong}},
+		{"unknown head element", Op{Kind: OpSet, Name: "generator", Value: "Go"}},
+		{"missing key", Op{Kind: OpRemove, Key: "text:Gone"}},
+		{"key at other path", Op{Kind: OpRemove, Key: "text:Tech", Path: []int{1}}},
+		{"existing key", Op{Kind: OpAdd, Path: []int{1, 0}, Outline: &Outline{Text: "Go", XMLURL: "http://example.com/Go"}}},
+		{"existing given key", Op{Kind: OpAdd, Key: "xmlUrl:http://example.com/Go", Path: []int{1, 0}, Outline: &Outline{Text: "Go", XMLURL: "http://example.com/Go"}}},
+		{"wrong key", Op{Kind: OpAdd, Key: "xmlUrl:http://example.com/Rust#2", Path: []int{1, 0}, Outline: &Outline{Text: "Go", XMLURL: "http://example.com/Go"}}},
+		{"missing parent", Op{Kind: OpAdd, Parent: "text:Gone", Outline: &Outline{Text: "Go"}}},
+		{"previous sibling elsewhere", Op{Kind: OpMove, Key: "text:Old", After: "text:News/HN"}},
+		{"own subtree", Op{Kind: OpMove, Key: "text:Tech", Parent: "xmlUrl:http://example.com/Go"}},
+		{"invalid path", Op{Kind: OpRemove, Path: []int{5}}},
+		{"unknown operation", Op{Kind: "copy"}},
+	}
+
+	for _, test := range tests {
+		doc := patchTestDoc()
+		before, _ := doc.XML()
+
+		// The first operation applies: the document must be left unchanged.
+		patch := Patch{{Kind: OpRemove, Path: []int{2}}, test.op}
+		err := Apply(doc, patch)
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) || conflict.Index != 1 {
+			t.Errorf("%s: expected a conflict on the second operation, found %v", test.name, err)
+			continue
+		}
+		if !errors.Is(err, ErrConflict) && !errors.Is(err, ErrInvalidPath) && !errors.Is(err, ErrInvalidMove) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if after, _ := doc.XML(); after != before {
+			t.Errorf("%s: document modified", test.name)
+		}
+	}
+}
+
+func TestMakePatch(t *testing.T) {
+	a := patchTestDoc()
+	tests := []func(b *OPML){
+		func(b *OPML) {
+			b.Head.Title = "My feeds"
+			b.Move([]int{0, 1}, []int{1, 1})
+			b.Move([]int{0, 1}, []int{0, 0})
+			b.Insert([]int{0, 2}, feed("Zig"))
+			b.Body.Outlines[0].Outlines[1].Title = "Golang"
+		},
+		func(b *OPML) {
+			// Reverse all lists.
+			b.WalkPost(func(o *Outline, depth int, path []int, parent *Outline) error {
+				for i, j := 0, len(o.Outlines)-1; i < j; i, j = i+1, j-1 {
+					o.Outlines[i], o.Outlines[j] = o.Outlines[j], o.Outlines[i]
+				}
+				return nil
+			})
+			l := b.Body.Outlines
+			l[0], l[2] = l[2], l[0]
+		},
+		func(b *OPML) {
+			// Remove a folder, keeping one of its feeds, and rename another.
+			b.Move([]int{2, 0}, []int{1, 0})
+			b.Remove([]int{2})
+			b.Body.Outlines[0].Text = "Dev"
+			b.Insert([]int{0, 0}, folder("Sub", feed("Hare")))
+		},
+		func(b *OPML) {
+			// Duplicate feeds, before and after the original one.
+			b.Insert([]int{1, 1}, feed("Go"))
+			b.Insert([]int{0, 0}, feed("Rust"))
+		},
+	}
+
+	for i, edit := range tests {
+		b := a.Clone()
+		edit(b)
+
+		patch := MakePatch(a, b)
+		data, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Patch
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		c := a.Clone()
+		if err := Apply(c, decoded); err != nil {
+			t.Errorf("%d: %v\n%s", i, err, data)
+			continue
+		}
+		if d := Compare(c, b); !d.Empty() {
+			t.Errorf("%d: patched document differs:\n%s\npatch: %s", i, d, data)
+		}
+		if texts(c.Body.Outlines) != texts(b.Body.Outlines) {
+			t.Errorf("%d: wrong tree: expected '%s', found '%s'",
+				i, texts(b.Body.Outlines), texts(c.Body.Outlines))
+		}
+	}
+}
+
+func TestMakePatchRenamedParent(t *testing.T) {
+	a := &OPML{Body: Body{Outlines: []Outline{feed("Go", Outline{Text: "old episode"})}}}
+	b := &OPML{Body: Body{Outlines: []Outline{feed("Go")}}}
+	b.Body.Outlines[0].Text = "Golang"
+
+	if err := Apply(a, MakePatch(a, b)); err != nil {
+		t.Fatal(err)
+	}
+	if expected, found := texts(b.Body.Outlines), texts(a.Body.Outlines); found != expected {
+		t.Errorf("Wrong tree: expected '%s', found '%s'", expected, found)
+	}
+}
+
+// randomOutlines returns random outlines, with few distinct texts and URLs
+// so that duplicate keys are frequent.
+func randomOutlines(r *rand.Rand, depth int) []Outline {
+	var outlines []Outline
+	for i := r.Intn(4); i > 0; i-- {
+		o := Outline{Text: string(rune('A' + r.Intn(4)))}
+		if r.Intn(2) == 0 {
+			o.XMLURL = "http://example.com/" + strconv.Itoa(r.Intn(6))
+		}
+		if depth > 0 {
+			o.Outlines = randomOutlines(r, depth-1)
+		}
+		outlines = append(outlines, o)
+	}
+	return outlines
+}
+
+// randomEdit applies a random edit to doc.
+func randomEdit(r *rand.Rand, doc *OPML) {
+	var paths [][]int
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		paths = append(paths, copyPath(path))
+		return nil
+	})
+	if len(paths) == 0 {
+		doc.Insert([]int{0}, Outline{Text: "E"})
+		return
+	}
+	path := paths[r.Intn(len(paths))]
+	switch r.Intn(5) {
+	case 0:
+		o, _ := doc.Outline(path)
+		o.Text = string(rune('A' + r.Intn(5)))
+	case 1:
+		o, _ := doc.Outline(path)
+		o.Title = strconv.Itoa(r.Intn(3))
+	case 2:
+		doc.Remove(path)
+	case 3:
+		o := randomOutlines(r, 1)
+		if len(o) > 0 {
+			doc.Insert(path, o[0])
+		}
+	case 4:
+		doc.Reparent(path, paths[r.Intn(len(paths))])
+	}
+}
+
+func TestMakePatchRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	failures := 0
+	for i := 0; i < 3000; i++ {
+		a := &OPML{Body: Body{Outlines: randomOutlines(r, 2)}}
+		b := a.Clone()
+		for n := r.Intn(6); n >= 0; n-- {
+			randomEdit(r, b)
+		}
+
+		c := a.Clone()
+		patch := MakePatch(a, b)
+		err := Apply(c, patch)
+		if err == nil {
+			expected, _ := b.XML()
+			found, _ := c.XML()
+			if found == expected {
+				continue
+			}
+			err = fmt.Errorf("patched document differs: expected '%s', found '%s'",
+				texts(b.Body.Outlines), texts(c.Body.Outlines))
+		}
+		if failures++; failures <= 3 {
+			data, _ := json.Marshal(patch)
+			t.Errorf("%d: %v\na: %s\npatch: %s", i, err, texts(a.Body.Outlines), data)
+		}
+	}
+	if failures > 0 {
+		t.Errorf("%d patches out of 3000 failed", failures)
+	}
+}
@@ -0,0 +1,558 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The selector language is modeled after CSS selectors. A selector is a
+// comma-separated list of alternatives, each made of compound selectors
+// separated by combinators:
+//
+//	a > b     b is a child of a
+//	a b       b is a descendant of a
+//
+// A compound selector starts with an optional type, followed by any number of
+// attribute conditions and pseudo-classes:
+//
+//	outline, *    any outline
+//	feed          outline with an xmlUrl attribute
+//	folder        outline without xmlUrl attribute, with children
+//
+//	[name]        the attribute is set
+//	[name=v]      the attribute is equal to v
+//	[name!=v]     the attribute is not equal to v
+//	[name^=v]     the attribute starts with v
+//	[name$=v]     the attribute ends with v
+//	[name*=v]     the attribute contains v
+//	[name~=re]    the attribute matches the regular expression re
+//
+//	:has(s)       the outline has a descendant matching s, or a child if s
+//	              starts with ">"
+//	:not(s)       the outline does not match s
+//	:depth(n)     the depth of the outline is n, 0 for top-level outlines;
+//	              n may be preceded by <, <=, > or >=
+//	:first-child  the outline is the first of its siblings
+//	:last-child   the outline is the last of its siblings
+//	:empty        the outline has no children
+//
+// Values may be quoted with double or single quotes, and must be if they
+// contain spaces or special characters. Attributes are named as by
+// Outline.Attr, e.g. [podcast:guid].
+//
+// For instance, `folder[text="Tech"] > feed[type=rss]` selects the RSS feeds
+// of the Tech folders, and `folder:not(:has(feed))` the folders without
+// feeds.
+
+// SelectorError describes a syntax error in a selector.
+type SelectorError struct {
+	Selector string
+	Offset   int // offset of the error in the selector, in bytes
+	Msg      string
+}
+
+func (e *SelectorError) Error() string {
+	return fmt.Sprintf("opml: invalid selector %q at offset %d: %s", e.Selector, e.Offset, e.Msg)
+}
+
+// Selector is a compiled selector.
+type Selector struct {
+	src  string
+	alts []complexSelector
+}
+
+// Match is an outline selected by a selector.
+type Match struct {
+	Outline *Outline
+	Path    []int
+}
+
+// Compile parses a selector.
+func Compile(selector string) (*Selector, error) {
+	p := &selectorParser{src: selector}
+	alts, err := p.parseList(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	}
+	return &Selector{src: selector, alts: alts}, nil
+}
+
+// MustCompile is like Compile but panics if the selector is invalid.
+func MustCompile(selector string) *Selector {
+	s, err := Compile(selector)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the source of the selector.
+func (s *Selector) String() string {
+	return s.src
+}
+
+// Select returns the outlines of doc matching the selector, in document
+// order.
+func (s *Selector) Select(doc *OPML) []Match {
+	var matches []Match
+	s.walk(doc, func(n *selectorNode) bool {
+		matches = append(matches, Match{n.outline, n.path()})
+		return true
+	})
+	return matches
+}
+
+// SelectOne returns the first outline of doc matching the selector, or nil if
+// there is none.
+func (s *Selector) SelectOne(doc *OPML) *Match {
+	var match *Match
+	s.walk(doc, func(n *selectorNode) bool {
+		match = &Match{n.outline, n.path()}
+		return false
+	})
+	return match
+}
+
+// Select returns the outlines matching the selector, in document order. See
+// Selector for the syntax of selectors.
+func (doc *OPML) Select(selector string) ([]Match, error) {
+	s, err := Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(doc), nil
+}
+
+// SelectOne returns the first outline matching the selector, or nil if there
+// is none. See Selector for the syntax of selectors.
+func (doc *OPML) SelectOne(selector string) (*Match, error) {
+	s, err := Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.SelectOne(doc), nil
+}
+
+// walk calls fn for each outline matching the selector, until it returns
+// false.
+func (s *Selector) walk(doc *OPML, fn func(n *selectorNode) bool) {
+	walkNodes(doc.Body.Outlines, nil, func(n *selectorNode) bool {
+		if s.match(n) {
+			return fn(n)
+		}
+		return true
+	})
+}
+
+func (s *Selector) match(n *selectorNode) bool {
+	for _, alt := range s.alts {
+		if alt.match(n, len(alt)-1, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorNode is an outline visited by a selector, with its context.
+type selectorNode struct {
+	outline  *Outline
+	parent   *selectorNode
+	index    int // index among siblings
+	siblings int // number of siblings, including the outline
+	depth    int
+}
+
+func (n *selectorNode) path() []int {
+	path := make([]int, n.depth+1)
+	for ; n != nil; n = n.parent {
+		path[n.depth] = n.index
+	}
+	return path
+}
+
+// walkNodes calls fn for each outline of the list and their descendants, in
+// pre-order, until it returns false.
+func walkNodes(outlines []Outline, parent *selectorNode, fn func(n *selectorNode) bool) bool {
+	depth := 0
+	if parent != nil {
+		depth = parent.depth + 1
+	}
+	for i := range outlines {
+		n := &selectorNode{&outlines[i], parent, i, len(outlines), depth}
+		if !fn(n) || !walkNodes(outlines[i].Outlines, n, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// complexSelector is a list of compound selectors. The combinator of each
+// compound selector relates it to the previous one.
+type complexSelector []compoundSelector
+
+// match reports whether the node matches the compound selectors up to index
+// i. The scope is the node matched by the leading ":scope" selector of the
+// relative selectors of :has.
+func (c complexSelector) match(n *selectorNode, i int, scope *selectorNode) bool {
+	if !c[i].match(n, scope) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch c[i].combinator {
+	case '>':
+		return n.parent != nil && c.match(n.parent, i-1, scope)
+	default:
+		for a := n.parent; a != nil; a = a.parent {
+			if c.match(a, i-1, scope) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type compoundSelector struct {
+	combinator byte // '>' or ' ', relating the selector to the previous one
+	scope      bool // matches the scope of :has only
+	typ        string
+	conds      []func(n *selectorNode) bool
+}
+
+func (c *compoundSelector) match(n *selectorNode, scope *selectorNode) bool {
+	if c.scope {
+		return n == scope
+	}
+	o := n.outline
+	switch c.typ {
+	case "feed":
+		if o.XMLURL == "" {
+			return false
+		}
+	case "folder":
+		if o.XMLURL != "" || len(o.Outlines) == 0 {
+			return false
+		}
+	}
+	for _, cond := range c.conds {
+		if !cond(n) {
+			return false
+		}
+	}
+	return true
+}
+
+type selectorParser struct {
+	src string
+	pos int
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return &SelectorError{p.src, p.pos, fmt.Sprintf(format, args...)}
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *selectorParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// parseList parses a comma-separated list of selectors, up to the end of the
+// input or a closing parenthesis. Relative selectors start with an implicit
+// ":scope" compound selector.
+func (p *selectorParser) parseList(relative bool) ([]complexSelector, error) {
+	var alts []complexSelector
+	for {
+		p.skipSpace()
+		var c complexSelector
+		if relative {
+			c = append(c, compoundSelector{scope: true})
+		}
+		for {
+			combinator := byte(' ')
+			if p.peek() == '>' {
+				if len(c) == 0 {
+					return nil, p.errorf("missing selector before '>'")
+				}
+				combinator = '>'
+				p.pos++
+				p.skipSpace()
+			}
+			compound, err := p.parseCompound()
+			if err != nil {
+				return nil, err
+			}
+			compound.combinator = combinator
+			c = append(c, compound)
+
+			space := p.skipSpace()
+			if p.pos == len(p.src) || p.peek() == ',' || p.peek() == ')' {
+				break
+			}
+			if !space && p.peek() != '>' {
+				return nil, p.errorf("unexpected %q", p.peek())
+			}
+		}
+		alts = append(alts, c)
+		if p.peek() != ',' {
+			return alts, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	var c compoundSelector
+	start := p.pos
+	if p.peek() == '*' {
+		p.pos++
+	} else if name := p.ident(); name != "" {
+		switch name {
+		case "outline", "feed", "folder":
+			c.typ = name
+		default:
+			p.pos = start
+			return c, p.errorf("unknown type %q", name)
+		}
+	}
+
+	for {
+		var cond func(n *selectorNode) bool
+		var err error
+		switch p.peek() {
+		case '[':
+			cond, err = p.parseAttr()
+		case ':':
+			cond, err = p.parsePseudo()
+		default:
+			if p.pos == start {
+				return c, p.errorf("missing selector")
+			}
+			return c, nil
+		}
+		if err != nil {
+			return c, err
+		}
+		c.conds = append(c.conds, cond)
+	}
+}
+
+// name parses a name made of letters, digits, '-', '_', '.' and ':', the
+// colon being only allowed in attribute names.
+func (p *selectorParser) name(attr bool) string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c >= 0x80 || attr && c == ':') {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *selectorParser) ident() string {
+	return p.name(false)
+}
+
+func (p *selectorParser) parseAttr() (func(n *selectorNode) bool, error) {
+	p.pos++ // [
+	p.skipSpace()
+	name := p.name(true)
+	if name == "" {
+		return nil, p.errorf("missing attribute name")
+	}
+	p.skipSpace()
+
+	var op string
+	for _, o := range []string{"=", "!=", "^=", "$=", "*=", "~="} {
+		if strings.HasPrefix(p.src[p.pos:], o) {
+			op = o
+		}
+	}
+	if op == "" {
+		if p.peek() != ']' {
+			return nil, p.errorf("expected ']'")
+		}
+		p.pos++
+		return func(n *selectorNode) bool { return n.outline.Attr(name) != "" }, nil
+	}
+	p.pos += len(op)
+	p.skipSpace()
+	valueStart := p.pos
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.peek() != ']' {
+		return nil, p.errorf("expected ']'")
+	}
+	p.pos++
+
+	var match func(s string) bool
+	switch op {
+	case "=":
+		match = func(s string) bool { return s == value }
+	case "!=":
+		match = func(s string) bool { return s != value }
+	case "^=":
+		match = func(s string) bool { return strings.HasPrefix(s, value) }
+	case "$=":
+		match = func(s string) bool { return strings.HasSuffix(s, value) }
+	case "*=":
+		match = func(s string) bool { return strings.Contains(s, value) }
+	case "~=":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			p.pos = valueStart
+			return nil, p.errorf("%v", err)
+		}
+		match = re.MatchString
+	}
+	return func(n *selectorNode) bool { return match(n.outline.Attr(name)) }, nil
+}
+
+// value parses a quoted or bare value.
+func (p *selectorParser) value() (string, error) {
+	q := p.peek()
+	if q != '"' && q != '\'' {
+		start := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] != ']' && !unicode.IsSpace(rune(p.src[p.pos])) {
+			p.pos++
+		}
+		if p.pos == start {
+			return "", p.errorf("missing value")
+		}
+		return p.src[start:p.pos], nil
+	}
+
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case c == q:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			b.WriteByte(p.src[p.pos])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *selectorParser) parsePseudo() (func(n *selectorNode) bool, error) {
+	p.pos++ // :
+	start := p.pos
+	name := p.ident()
+	switch name {
+	case "first-child":
+		return func(n *selectorNode) bool { return n.index == 0 }, nil
+	case "last-child":
+		return func(n *selectorNode) bool { return n.index == n.siblings-1 }, nil
+	case "empty":
+		return func(n *selectorNode) bool { return len(n.outline.Outlines) == 0 }, nil
+	case "has", "not", "depth":
+	default:
+		p.pos = start
+		return nil, p.errorf("unknown pseudo-class %q", name)
+	}
+
+	if p.peek() != '(' {
+		return nil, p.errorf("expected '('")
+	}
+	p.pos++
+
+	var cond func(n *selectorNode) bool
+	switch name {
+	case "has":
+		alts, err := p.parseList(true)
+		if err != nil {
+			return nil, err
+		}
+		s := &Selector{alts: alts}
+		cond = func(n *selectorNode) bool {
+			found := false
+			walkNodes(n.outline.Outlines, n, func(d *selectorNode) bool {
+				for _, alt := range s.alts {
+					if alt.match(d, len(alt)-1, n) {
+						found = true
+						return false
+					}
+				}
+				return true
+			})
+			return found
+		}
+	case "not":
+		alts, err := p.parseList(false)
+		if err != nil {
+			return nil, err
+		}
+		s := &Selector{alts: alts}
+		cond = func(n *selectorNode) bool { return !s.match(n) }
+	case "depth":
+		p.skipSpace()
+		var op string
+		for _, o := range []string{"<=", ">=", "<", ">"} {
+			if op == "" && strings.HasPrefix(p.src[p.pos:], o) {
+				op = o
+			}
+		}
+		p.pos += len(op)
+		p.skipSpace()
+		numStart := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		depth, err := strconv.Atoi(p.src[numStart:p.pos])
+		if err != nil {
+			p.pos = numStart
+			return nil, p.errorf("expected depth")
+		}
+		p.skipSpace()
+		cond = func(n *selectorNode) bool {
+			switch op {
+			case "<":
+				return n.depth < depth
+			case "<=":
+				return n.depth <= depth
+			case ">":
+				return n.depth > depth
+			case ">=":
+				return n.depth >= depth
+			}
+			return n.depth == depth
+		}
+	}
+
+	if p.peek() != ')' {
+		return nil, p.errorf("expected ')'")
+	}
+	p.pos++
+	return cond, nil
+}
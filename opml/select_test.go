@@ -0,0 +1,120 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func selectTestDoc() *OPML {
+	return &OPML{Body: Body{Outlines: []Outline{
+		folder("Tech",
+			Outline{Text: "Go", Type: "rss", XMLURL: "https://go.dev/feed"},
+			folder("Lang",
+				Outline{Text: "Rust", Type: "atom", XMLURL: "https://blog.rust-lang.org/feed.xml"}),
+			Outline{Text: "Docs", Type: "link", URL: "https://go.dev/doc"}),
+		folder("News",
+			Outline{Text: "HN", Type: "rss", XMLURL: "https://news.ycombinator.com/rss"}),
+		{Text: "Empty"},
+	}}}
+}
+
+func TestSelect(t *testing.T) {
+	doc := selectTestDoc()
+	tests := []struct {
+		selector string
+		expected []string
+	}{
+		{`folder`, []string{"Tech", "Lang", "News"}},
+		{`*`, []string{"Tech", "Go", "Lang", "Rust", "Docs", "News", "HN", "Empty"}},
+		{`feed`, []string{"Go", "Rust", "HN"}},
+		{`folder[text="Tech"] > outline[type=rss]`, []string{"Go"}},
+		{`folder[text="Tech"] feed`, []string{"Go", "Rust"}},
+		{`folder[text=Tech]>feed`, []string{"Go"}},
+		{`[xmlUrl~="^https://(go|news)\."]`, []string{"Go", "HN"}},
+		{`[url]`, []string{"Docs"}},
+		{`[type!=rss]`, []string{"Tech", "Lang", "Rust", "Docs", "News", "Empty"}},
+		{`[xmlUrl^='https://blog.'], [url$=/doc]`, []string{"Rust", "Docs"}},
+		{`[text*=o]`, []string{"Go", "Docs"}},
+		{`:depth(0)`, []string{"Tech", "News", "Empty"}},
+		{`:depth(>=2)`, []string{"Rust"}},
+		{`feed:depth(<1), feed:depth(> 1)`, []string{"Rust"}},
+		{`folder:has(feed[type=atom])`, []string{"Tech", "Lang"}},
+		{`folder:has(> feed[type=atom])`, []string{"Lang"}},
+		{`folder:has(> folder > feed)`, []string{"Tech"}},
+		{`outline:not(folder, feed)`, []string{"Docs", "Empty"}},
+		{`:first-child`, []string{"Tech", "Go", "Rust", "HN"}},
+		{`:last-child:empty`, []string{"Rust", "Docs", "HN", "Empty"}},
+		{`folder > :last-child`, []string{"Rust", "Docs", "HN"}},
+		{`[type=none]`, nil},
+	}
+
+	for _, test := range tests {
+		matches, err := doc.Select(test.selector)
+		if err != nil {
+			t.Errorf("%s: %v", test.selector, err)
+			continue
+		}
+		var found []string
+		for _, m := range matches {
+			found = append(found, m.Outline.Text)
+			if o, _ := doc.Outline(m.Path); o != m.Outline {
+				t.Errorf("%s: wrong path %v for %s", test.selector, m.Path, m.Outline.Text)
+			}
+		}
+		if !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("%s: expected %v, found %v", test.selector, test.expected, found)
+		}
+	}
+}
+
+func TestSelectOne(t *testing.T) {
+	doc := selectTestDoc()
+	m, err := doc.SelectOne(`folder folder feed`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Outline.Text != "Rust" || !reflect.DeepEqual(m.Path, []int{0, 1, 0}) {
+		t.Errorf("Wrong match: %+v", m)
+	}
+	if m, err := doc.SelectOne(`feed feed`); m != nil || err != nil {
+		t.Errorf("Unexpected match: %+v, %v", m, err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		selector string
+		offset   int
+	}{
+		{``, 0},
+		{`item`, 0},
+		{`> feed`, 0},
+		{`feed,`, 5},
+		{`feed >`, 6},
+		{`[text`, 5},
+		{`[text=]`, 6},
+		{`[text="Go]`, 10},
+		{`[text~="("]`, 7},
+		{`:nth-child(1)`, 1},
+		{`:has(feed`, 9},
+		{`:depth(x)`, 7},
+		{`feed)`, 4},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(test.selector)
+		var e *SelectorError
+		if !errors.As(err, &e) {
+			t.Errorf("%s: expected SelectorError, found %v", test.selector, err)
+			continue
+		}
+		if e.Offset != test.offset {
+			t.Errorf("%s: expected offset %d, found %d (%v)", test.selector, test.offset, e.Offset, err)
+		}
+	}
+}
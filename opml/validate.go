@@ -0,0 +1,340 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// Severity tells how serious a problem reported by Validate is.
+type Severity int
+
+// Severities of diagnostics.
+const (
+	// SeverityError is used for violations of the specification.
+	SeverityError Severity = iota
+	// SeverityWarning is used for discouraged or non-conformant constructs
+	// that most readers accept.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic is a problem found by Validate.
+type Diagnostic struct {
+	Severity Severity
+	Rule     string // identifier of the rule, e.g. "outline-text-missing"
+	Message  string
+
+	// Path is the index path of the outline the diagnostic is about, or nil
+	// if it is about the document or its head.
+	Path []int
+
+	// Line and Column give the position of the problem in the input, both
+	// starting at 1. They are 0 when the position is not known.
+	Line, Column int
+}
+
+func (d Diagnostic) String() string {
+	var pos string
+	if d.Line > 0 {
+		pos = fmt.Sprintf("%d:%d: ", d.Line, d.Column)
+	}
+	return fmt.Sprintf("%s%s: %s [%s]", pos, d.Severity, d.Message, d.Rule)
+}
+
+// Validate reads an OPML document and checks it against the OPML 1.0 and 2.0
+// specifications, depending on its version. Problems are reported as
+// diagnostics, with their position in the input. Malformed XML is reported
+// as an "xml-syntax" diagnostic; the returned error is only set if reading
+// fails.
+//
+// The following rules are checked:
+//
+//	xml-syntax              the document is not well-formed XML
+//	root-invalid            the root element is not <opml>
+//	version-missing         <opml> has no version attribute
+//	version-unknown         the version is not 1.0, 1.1 or 2.0
+//	head-missing            the document has no <head>
+//	body-missing            the document has no <body>
+//	body-empty              <body> contains no outline
+//	date-invalid            a date cannot be parsed
+//	date-nonconformant      a date is readable but not in RFC 822 format
+//	email-invalid           ownerEmail is not an email address
+//	url-invalid             a URL attribute or element is not an absolute URL
+//	number-invalid          expansionState is not made of positive integers,
+//	                        or vertScrollState or a window element is not a
+//	                        non-negative integer
+//	outline-text-missing    an outline has no text attribute
+//	outline-xmlurl-missing  an outline of type rss has no xmlUrl attribute
+//	outline-url-missing     an outline of type link or include has no url
+//	                        attribute
+//	boolean-invalid         isComment or isBreakpoint is neither "true" nor
+//	                        "false"
+func Validate(r io.Reader) ([]Diagnostic, error) {
+	d := NewDecoder(r)
+	v := validator{}
+
+	if err := v.decode(d); err != nil {
+		var syntaxErr *xml.SyntaxError
+		switch {
+		case errors.As(err, &syntaxErr):
+			pos := d.position()
+			v.add(SeverityError, "xml-syntax", nil, pos.line, pos.col, "%s", syntaxErr.Msg)
+		case errors.Is(err, ErrNotOPML):
+			pos := d.position()
+			v.add(SeverityError, "root-invalid", nil, pos.line, pos.col, "%s",
+				strings.TrimPrefix(err.Error(), ErrNotOPML.Error()+": "))
+		default:
+			return nil, err
+		}
+	}
+
+	return v.diags, nil
+}
+
+// Validate checks the document against the OPML 1.0 and 2.0 specifications,
+// depending on its version. See the Validate function for the list of rules.
+// The diagnostics have no position.
+func (doc *OPML) Validate() []Diagnostic {
+	v := validator{version: doc.Version}
+	v.root(0, 0)
+	v.head(&doc.Head, func(string) (int, int) { return 0, 0 })
+	if len(doc.Body.Outlines) == 0 {
+		v.emptyBody(0, 0)
+	}
+	doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		v.outline(o, copyPath(path), 0, 0)
+		return nil
+	})
+	return v.diags
+}
+
+type validator struct {
+	version string
+	diags   []Diagnostic
+}
+
+func (v *validator) add(sev Severity, rule string, path []int, line, col int,
+	format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{
+		Severity: sev,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+		Path:     path,
+		Line:     line,
+		Column:   col,
+	})
+}
+
+// decode validates the document read by d as it is decoded.
+func (v *validator) decode(d *Decoder) error {
+	var err error
+	if v.version, err = d.Version(); err != nil {
+		return err
+	}
+	root := d.positions["opml"]
+	v.root(root.line, root.col)
+
+	head, err := d.Head()
+	if err != nil {
+		return err
+	}
+	if head == nil {
+		pos, ok := d.positions["body"]
+		if !ok {
+			pos = d.position()
+		}
+		v.add(SeverityError, "head-missing", nil, pos.line, pos.col, "missing <head> element")
+	} else {
+		v.head(head, func(field string) (int, int) {
+			pos, ok := d.positions[field]
+			if !ok {
+				pos = d.positions["head"]
+			}
+			return pos.line, pos.col
+		})
+	}
+
+	outlines := 0
+	for {
+		n, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		outlines++
+		v.outline(&n.Outline, n.Path, n.Line, n.Column)
+	}
+
+	if !d.body {
+		pos := d.position()
+		v.add(SeverityError, "body-missing", nil, pos.line, pos.col, "missing <body> element")
+	} else if outlines == 0 {
+		body := d.positions["body"]
+		v.emptyBody(body.line, body.col)
+	}
+	return nil
+}
+
+func (v *validator) root(line, col int) {
+	switch v.version {
+	case "":
+		v.add(SeverityError, "version-missing", nil, line, col,
+			"missing version attribute on <opml>")
+	case "1.0", "1.1", "2.0":
+	default:
+		v.add(SeverityWarning, "version-unknown", nil, line, col,
+			"unknown OPML version %q", v.version)
+	}
+}
+
+func (v *validator) emptyBody(line, col int) {
+	sev := SeverityWarning
+	if v.version == "2.0" {
+		sev = SeverityError
+	}
+	v.add(sev, "body-empty", nil, line, col, "<body> contains no outline")
+}
+
+func (v *validator) head(h *Head, pos func(field string) (int, int)) {
+	date := func(field, value string) {
+		if value == "" {
+			return
+		}
+		line, col := pos(field)
+		_, strict, err := parseDate(value)
+		switch {
+		case err != nil:
+			v.add(SeverityError, "date-invalid", nil, line, col,
+				"invalid date in <%s>: %q", field, value)
+		case !strict:
+			v.add(SeverityWarning, "date-nonconformant", nil, line, col,
+				"date in <%s> is not in RFC 822 format: %q", field, value)
+		}
+	}
+	date("dateCreated", h.DateCreated)
+	date("dateModified", h.DateModified)
+
+	if h.OwnerEmail != "" {
+		if _, err := mail.ParseAddress(h.OwnerEmail); err != nil {
+			line, col := pos("ownerEmail")
+			v.add(SeverityError, "email-invalid", nil, line, col,
+				"invalid email address in <ownerEmail>: %q", h.OwnerEmail)
+		}
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"ownerId", h.OwnerID},
+		{"docs", h.Docs},
+	} {
+		if field.value != "" && !isAbsURL(field.value) {
+			line, col := pos(field.name)
+			v.add(SeverityWarning, "url-invalid", nil, line, col,
+				"<%s> is not an absolute URL: %q", field.name, field.value)
+		}
+	}
+
+	if _, err := h.Expansion(); err != nil {
+		line, col := pos("expansionState")
+		v.add(SeverityError, "number-invalid", nil, line, col,
+			"invalid line numbers in <expansionState>: %q", h.ExpansionState)
+	}
+	for _, field := range []struct{ name, value string }{
+		{"vertScrollState", h.VertScrollState},
+		{"windowTop", h.WindowTop},
+		{"windowLeft", h.WindowLeft},
+		{"windowBottom", h.WindowBottom},
+		{"windowRight", h.WindowRight},
+	} {
+		if _, err := headInt(field.name, field.value); err != nil {
+			line, col := pos(field.name)
+			v.add(SeverityError, "number-invalid", nil, line, col,
+				"invalid number in <%s>: %q", field.name, field.value)
+		}
+	}
+}
+
+func (v *validator) outline(o *Outline, path []int, line, col int) {
+	if o.Text == "" {
+		sev := SeverityWarning
+		if v.version == "2.0" {
+			sev = SeverityError
+		}
+		v.add(sev, "outline-text-missing", path, line, col,
+			"outline has no text attribute")
+	}
+
+	switch strings.ToLower(o.Type) {
+	case "rss":
+		if o.XMLURL == "" {
+			v.add(SeverityError, "outline-xmlurl-missing", path, line, col,
+				"outline of type rss has no xmlUrl attribute")
+		}
+	case "link", "include":
+		if o.URL == "" {
+			v.add(SeverityError, "outline-url-missing", path, line, col,
+				"outline of type %s has no url attribute", o.Type)
+		}
+	}
+
+	for _, attr := range []struct{ name, value string }{
+		{"xmlUrl", o.XMLURL},
+		{"htmlUrl", o.HTMLURL},
+		{"url", o.URL},
+	} {
+		if attr.value != "" && !isAbsURL(attr.value) {
+			sev := SeverityWarning
+			if attr.name == "xmlUrl" {
+				sev = SeverityError
+			}
+			v.add(sev, "url-invalid", path, line, col,
+				"%s attribute is not an absolute URL: %q", attr.name, attr.value)
+		}
+	}
+
+	if o.Created != "" {
+		_, strict, err := parseDate(o.Created)
+		switch {
+		case err != nil:
+			v.add(SeverityError, "date-invalid", path, line, col,
+				"invalid date in created attribute: %q", o.Created)
+		case !strict:
+			v.add(SeverityWarning, "date-nonconformant", path, line, col,
+				"date in created attribute is not in RFC 822 format: %q", o.Created)
+		}
+	}
+
+	for _, attr := range []struct{ name, value string }{
+		{"isComment", o.IsComment},
+		{"isBreakpoint", o.IsBreakpoint},
+	} {
+		if attr.value != "" && attr.value != "true" && attr.value != "false" {
+			v.add(SeverityError, "boolean-invalid", path, line, col,
+				"%s attribute must be \"true\" or \"false\": %q", attr.name, attr.value)
+		}
+	}
+}
+
+func isAbsURL(s string) bool {
+	u, err := url.Parse(strings.TrimSpace(s))
+	return err == nil && u.IsAbs() && (u.Host != "" || u.Opaque != "")
+}
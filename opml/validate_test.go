@@ -0,0 +1,170 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const invalidOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+	<head>
+		<title>Invalid</title>
+		<dateCreated>Monday, 31 October 2005 19:23:00 GMT</dateCreated>
+		<dateModified>yesterday</dateModified>
+		<ownerEmail>not an email</ownerEmail>
+		<windowTop>top</windowTop>
+	</head>
+	<body>
+		<outline text="Tech">
+			<outline type="rss" htmlUrl="http://example.com/"/>
+			<outline text="Go" type="link" isComment="yes"/>
+		</outline>
+		<outline text="News" type="rss" xmlUrl="news.xml" created="Mon, 31 Oct 2005 19:23:00 GMT"/>
+	</body>
+</opml>`
+
+func diagnosticStrings(diags []Diagnostic) []string {
+	s := make([]string, len(diags))
+	for i, d := range diags {
+		s[i] = fmt.Sprintf("%d:%d %s %s %v", d.Line, d.Column, d.Severity, d.Rule, d.Path)
+	}
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	diags, err := Validate(strings.NewReader(invalidOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"5:3 warning date-nonconformant []",
+		"6:3 error date-invalid []",
+		"7:3 error email-invalid []",
+		"8:3 error number-invalid []",
+		"12:4 error outline-text-missing [0 0]",
+		"12:4 error outline-xmlurl-missing [0 0]",
+		"13:4 error outline-url-missing [0 1]",
+		"13:4 error boolean-invalid [0 1]",
+		"15:3 error url-invalid [1]",
+	}
+	if found := diagnosticStrings(diags); !reflect.DeepEqual(found, expected) {
+		t.Errorf("Wrong diagnostics: expected\n%s\nfound\n%s",
+			strings.Join(expected, "\n"), strings.Join(found, "\n"))
+	}
+}
+
+func TestValidateEncodings(t *testing.T) {
+	plain, err := Validate(strings.NewReader(invalidOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := diagnosticStrings(plain)
+
+	utf16, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(invalidOPML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latin1, err := charmap.ISO8859_1.NewEncoder().String(
+		strings.Replace(strings.Replace(invalidOPML, "UTF-8", "ISO-8859-1", 1), "Invalid", "Invalidé", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]string{
+		"UTF-8 BOM":  "\xef\xbb\xbf" + invalidOPML,
+		"UTF-16":     utf16,
+		"ISO-8859-1": latin1,
+	}
+
+	for name, doc := range docs {
+		diags, err := Validate(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if found := diagnosticStrings(diags); !reflect.DeepEqual(found, expected) {
+			t.Errorf("%s: wrong diagnostics: expected\n%s\nfound\n%s", name,
+				strings.Join(expected, "\n"), strings.Join(found, "\n"))
+		}
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	diags, err := Validate(strings.NewReader(nestedOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("Unexpected diagnostics: %v", diags)
+	}
+}
+
+func TestValidateStructure(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected []string
+	}{
+		{
+			`<opml><head/><body><outline/></body></opml>`,
+			[]string{"1:1 error version-missing []", "1:20 warning outline-text-missing [0]"},
+		},
+		{
+			"<?xml version=\"1.0\"?>\n<opml version=\"2.0\">\n<body>\n</body></opml>",
+			[]string{"3:1 error head-missing []", "3:1 error body-empty []"},
+		},
+		{
+			`<opml version="3.0"><head></head></opml>`,
+			[]string{"1:1 warning version-unknown []", "1:41 error body-missing []"},
+		},
+		{
+			`<opml version="2.0"><head><vertScrollState>-3</vertScrollState><windowTop>0</windowTop></head><body><outline text="a"/></body></opml>`,
+			[]string{"1:27 error number-invalid []"},
+		},
+		{
+			`<rss version="2.0"></rss>`,
+			[]string{"1:20 error root-invalid []"},
+		},
+		{
+			"<opml version=\"1.0\">\n<head><title>Foo</head></opml>",
+			[]string{"2:24 error xml-syntax []"},
+		},
+	}
+
+	for _, test := range tests {
+		diags, err := Validate(strings.NewReader(test.doc))
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", test.doc, err)
+			continue
+		}
+		if found := diagnosticStrings(diags); !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("Wrong diagnostics for %s: expected %v, found %v",
+				test.doc, test.expected, found)
+		}
+	}
+}
+
+func TestOPMLValidate(t *testing.T) {
+	doc, err := NewOPML([]byte(invalidOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := doc.Validate()
+	if len(diags) != 9 {
+		t.Fatalf("Wrong number of diagnostics: expected 9, found %d: %v", len(diags), diags)
+	}
+	if diags[4].Rule != "outline-text-missing" || !reflect.DeepEqual(diags[4].Path, []int{0, 0}) {
+		t.Errorf("Wrong diagnostic: %v", diags[4])
+	}
+	if diags[4].Line != 0 {
+		t.Errorf("Unexpected position for an in-memory document: %v", diags[4])
+	}
+}
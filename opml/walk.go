@@ -0,0 +1,90 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+)
+
+// SkipChildren is used as a return value from WalkFuncs to indicate that the
+// children of the outline are to be skipped. It is not returned as an error
+// by any function.
+var SkipChildren = errors.New("skip children")
+
+// Stop is used as a return value from WalkFuncs to indicate that the walk is
+// to be stopped. It is not returned as an error by any function.
+var Stop = errors.New("stop walk")
+
+// WalkFunc is the type of the function called for each outline visited by
+// Walk and WalkPost. The depth is 0 for top-level outlines, path is the index
+// path of the outline and parent is nil for top-level outlines.
+//
+// The outline may be modified in place, including its children when walking
+// in pre-order. The path slice is reused between calls and must be copied to
+// be retained.
+//
+// If the function returns SkipChildren, the children of the outline are not
+// visited. If it returns Stop, the walk ends without error. Any other non-nil
+// error stops the walk and is returned.
+type WalkFunc func(o *Outline, depth int, path []int, parent *Outline) error
+
+// Walk visits the outlines of the document in pre-order, i.e. each outline is
+// visited before its children.
+func (doc *OPML) Walk(fn WalkFunc) error {
+	w := walker{fn: fn}
+	return stopped(w.walk(doc.Body.Outlines, nil, 0))
+}
+
+// WalkPost visits the outlines of the document in post-order, i.e. each
+// outline is visited after its children. Returning SkipChildren from fn has
+// no effect.
+func (doc *OPML) WalkPost(fn WalkFunc) error {
+	w := walker{fn: fn, post: true}
+	return stopped(w.walk(doc.Body.Outlines, nil, 0))
+}
+
+type walker struct {
+	fn   WalkFunc
+	post bool
+	path []int
+}
+
+func (w *walker) walk(outlines []Outline, parent *Outline, depth int) error {
+	w.path = append(w.path, 0)
+	for i := range outlines {
+		w.path[depth] = i
+		o := &outlines[i]
+
+		if !w.post {
+			err := w.fn(o, depth, w.path[:depth+1], parent)
+			if err == SkipChildren {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := w.walk(o.Outlines, o, depth+1); err != nil {
+			return err
+		}
+
+		if w.post {
+			err := w.fn(o, depth, w.path[:depth+1], parent)
+			if err != nil && err != SkipChildren {
+				return err
+			}
+		}
+	}
+	w.path = w.path[:depth]
+	return nil
+}
+
+func stopped(err error) error {
+	if err == Stop {
+		return nil
+	}
+	return err
+}
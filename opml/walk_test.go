@@ -0,0 +1,123 @@
+// Copyright 2014 The project AUTHORS. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package opml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func walkTestDoc(t *testing.T) *OPML {
+	doc, err := NewOPML([]byte(nestedOPML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func visit(visited *[]string) WalkFunc {
+	return func(o *Outline, depth int, path []int, parent *Outline) error {
+		parentText := ""
+		if parent != nil {
+			parentText = parent.Text
+		}
+		*visited = append(*visited, fmt.Sprintf("%s:%d:%v:%s", o.Text, depth, path, parentText))
+		return nil
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var visited []string
+	if err := walkTestDoc(t).Walk(visit(&visited)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"Tech:0:[0]:",
+		"Go:1:[0 0]:Tech",
+		"Lang:1:[0 1]:Tech",
+		"Rust:2:[0 1 0]:Lang",
+		"News:0:[1]:",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Wrong pre-order walk: expected %v, found %v", expected, visited)
+	}
+}
+
+func TestWalkPost(t *testing.T) {
+	var visited []string
+	if err := walkTestDoc(t).WalkPost(visit(&visited)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"Go:1:[0 0]:Tech",
+		"Rust:2:[0 1 0]:Lang",
+		"Lang:1:[0 1]:Tech",
+		"Tech:0:[0]:",
+		"News:0:[1]:",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Wrong post-order walk: expected %v, found %v", expected, visited)
+	}
+}
+
+func TestWalkSkipAndStop(t *testing.T) {
+	doc := walkTestDoc(t)
+
+	var visited []string
+	err := doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		visited = append(visited, o.Text)
+		switch o.Text {
+		case "Lang":
+			return SkipChildren
+		case "News":
+			return Stop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Tech", "Go", "Lang", "News"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("Wrong walk: expected %v, found %v", expected, visited)
+	}
+
+	errFoo := errors.New("foo")
+	err = doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		return errFoo
+	})
+	if err != errFoo {
+		t.Errorf("Expected error '%v', found '%v'", errFoo, err)
+	}
+}
+
+func TestWalkMutation(t *testing.T) {
+	doc := walkTestDoc(t)
+
+	err := doc.Walk(func(o *Outline, depth int, path []int, parent *Outline) error {
+		o.Text = strings.ToUpper(o.Text)
+		if o.Text == "GO" {
+			o.Outlines = append(o.Outlines, Outline{Text: "Added"})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tech := doc.Body.Outlines[0]
+	if tech.Text != "TECH" || tech.Outlines[1].Outlines[0].Text != "RUST" {
+		t.Errorf("Outlines not modified in place: %+v", tech)
+	}
+	if len(tech.Outlines[0].Outlines) != 1 || tech.Outlines[0].Outlines[0].Text != "ADDED" {
+		t.Errorf("Added child not visited: %+v", tech.Outlines[0])
+	}
+}